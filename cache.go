@@ -0,0 +1,102 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+)
+
+// decoderCacheKey identifies a decoded value by the field type, the raw value
+// and the field's tags, since tags such as trim and pipe change what reaches
+// the Decoder.
+type decoderCacheKey struct {
+	typ   reflect.Type
+	value string
+	tags  reflect.StructTag
+}
+
+// decoderCacheSize bounds the number of decoded values kept. Once it is
+// reached the least recently used entry is evicted, so services that reload
+// with ever-changing values don't grow the cache without limit.
+const decoderCacheSize = 256
+
+// decoderCacheEntry is the payload of an element of decoderCache.order.
+type decoderCacheEntry struct {
+	key   decoderCacheKey
+	value reflect.Value
+}
+
+//nolint:gochecknoglobals
+var decoderCache = struct {
+	sync.Mutex
+	order  *list.List // most recently used first
+	values map[decoderCacheKey]*list.Element
+}{order: list.New(), values: make(map[decoderCacheKey]*list.Element)}
+
+// cachedDecode returns the cached value for key, marking it recently used.
+func cachedDecode(key decoderCacheKey) (reflect.Value, bool) {
+	decoderCache.Lock()
+	defer decoderCache.Unlock()
+	e, ok := decoderCache.values[key]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	decoderCache.order.MoveToFront(e)
+	return e.Value.(decoderCacheEntry).value, true
+}
+
+// storeDecode records value for key, evicting the least recently used entry
+// when the cache is full.
+func storeDecode(key decoderCacheKey, value reflect.Value) {
+	decoderCache.Lock()
+	defer decoderCache.Unlock()
+	if e, ok := decoderCache.values[key]; ok {
+		e.Value = decoderCacheEntry{key, value}
+		decoderCache.order.MoveToFront(e)
+		return
+	}
+	decoderCache.values[key] = decoderCache.order.PushFront(decoderCacheEntry{key, value})
+	if decoderCache.order.Len() > decoderCacheSize {
+		oldest := decoderCache.order.Back()
+		decoderCache.order.Remove(oldest)
+		delete(decoderCache.values, oldest.Value.(decoderCacheEntry).key)
+	}
+}
+
+// processFieldCached behaves like processField, but consults the decoder
+// cache first and records successful results in it.
+func processFieldCached(value string, field reflect.Value, tags reflect.StructTag, options Options) error {
+	key := decoderCacheKey{typ: field.Type(), value: value, tags: tags}
+
+	if cached, ok := cachedDecode(key); ok {
+		field.Set(copyValue(cached))
+		return nil
+	}
+
+	if err := processField(value, field, tags, options); err != nil {
+		return err
+	}
+
+	storeDecode(key, copyValue(field))
+	return nil
+}
+
+// copyValue returns a shallow copy of v. Pointers are followed so that
+// cached results are never shared between fields.
+func copyValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(v.Elem())
+		return p
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
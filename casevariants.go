@@ -0,0 +1,55 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// caseVariants groups the names of the environment variables that start with
+// prefix, ignoring case, and returns the groups with more than one spelling.
+func caseVariants(environ []string, prefix string) [][]string {
+	prefix = strings.ToUpper(prefix)
+	if prefix != "" {
+		prefix += "_"
+	}
+
+	groups := make(map[string][]string)
+	for _, env := range environ {
+		key := strings.SplitN(env, "=", 2)[0]
+		upper := strings.ToUpper(key)
+		if !strings.HasPrefix(upper, prefix) {
+			continue
+		}
+		groups[upper] = append(groups[upper], key)
+	}
+
+	var variants [][]string
+	for _, keys := range groups {
+		if len(keys) > 1 {
+			sort.Strings(keys)
+			variants = append(variants, keys)
+		}
+	}
+	sort.Slice(variants, func(i, j int) bool { return variants[i][0] < variants[j][0] })
+	return variants
+}
+
+// warnCaseVariants reports every group of case-variant duplicates found in
+// the environment.
+func warnCaseVariants(options Options) {
+	report := options.CaseVariantFunc
+	if report == nil {
+		report = func(keys []string) {
+			log.Printf("envconfig: environment variables differ only by case: %s", strings.Join(keys, ", "))
+		}
+	}
+	for _, keys := range caseVariants(os.Environ(), options.Prefix) {
+		report(keys)
+	}
+}
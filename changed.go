@@ -0,0 +1,80 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import "reflect"
+
+// Snapshot returns the current values of the environment variables the
+// specified struct reads, keyed by variable name. Unset variables are left
+// out. Pass the result to a later ProcessChanged call.
+func Snapshot(prefix string, spec interface{}) (map[string]string, error) {
+	return SnapshotX(spec, Options{Prefix: prefix})
+}
+
+// SnapshotX is like Snapshot but takes Options, reading the variables
+// through options.LookupFunc.
+func SnapshotX(spec interface{}, options Options) (map[string]string, error) {
+	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]string)
+	for _, info := range infos {
+		for _, key := range lookupKeys(info, options) {
+			if value, ok := options.lookup(key); ok {
+				env[key] = value
+			}
+		}
+	}
+	return env, nil
+}
+
+// ProcessChanged updates only the fields of the specified struct whose
+// variables differ between prevEnv and the current environment, leaving the
+// others as they are, and returns the changed variable names. A variable
+// that was set or unset since the snapshot counts as changed, and a field
+// whose variables are all unset now is reset to its zero value before it is
+// processed again, so it ends up as Process would leave a fresh struct.
+func ProcessChanged(prefix string, spec interface{}, prevEnv map[string]string) ([]string, error) {
+	return ProcessChangedX(spec, prevEnv, Options{Prefix: prefix})
+}
+
+// ProcessChangedX is like ProcessChanged but takes Options, reading the
+// variables through options.LookupFunc. Pass it a snapshot taken by
+// SnapshotX with the same options.
+func ProcessChangedX(spec interface{}, prevEnv map[string]string, options Options) ([]string, error) {
+	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	fields := make(map[string]bool)
+	for _, info := range infos {
+		set := false
+		for _, key := range lookupKeys(info, options) {
+			value, ok := options.lookup(key)
+			prev, prevOk := prevEnv[key]
+			if ok != prevOk || value != prev {
+				changed = append(changed, key)
+				fields[info.Key] = true
+			}
+			set = set || ok
+		}
+		if fields[info.Key] && !set {
+			// the variable went away, so the field must not keep the value
+			// it was given from it
+			info.Field.Set(reflect.Zero(info.Field.Type()))
+		}
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+
+	options.only = func(info varInfo) bool { return fields[info.Key] }
+	err = ProcessX(spec, options)
+	return changed, err
+}
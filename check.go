@@ -0,0 +1,71 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// CheckSpec reports mistakes in a specification type that would otherwise
+// surface only when processing: malformed tags, fields that map to the same
+// variable, and defaults that fail to parse or validate. It neither reads the
+// environment nor touches spec, which may be a struct, a pointer to one, or
+// a typed nil pointer such as (*Config)(nil), so it suits a test or an init
+// function.
+func CheckSpec(spec interface{}) error {
+	return CheckSpecX(spec, Options{})
+}
+
+// CheckSpecX is like CheckSpec but takes Options, so keys follow Prefix,
+// SplitWords, TagName and KeyMapper as they do for ProcessX.
+func CheckSpecX(spec interface{}, options Options) error {
+	t := reflect.TypeOf(spec)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return ErrInvalidSpecification
+	}
+
+	// work on a fresh instance, so defaults can be applied for real
+	infos, err := gatherInfo(reflect.New(t).Interface(), options)
+	if err != nil {
+		return err
+	}
+	if err := checkRequiredIfTags(infos, options); err != nil {
+		return err
+	}
+
+	var errs []error
+	seen := make(map[string]string)
+	for _, info := range infos {
+		key := options.mapKey(info.Key)
+		if other, ok := seen[key]; ok {
+			errs = append(errs, fmt.Errorf("fields %s and %s both use key %s", other, info.Name, key))
+		}
+		seen[key] = info.Name
+
+		def := info.Tags.Get("default")
+		if def == "" || def == zeroDefault {
+			continue
+		}
+		process := processField
+		switch {
+		case def == "now" && isTime(info.Field.Type()):
+			process = setNow
+		case info.Setter.IsValid():
+			process = info.callSetter
+		}
+		if err := parseValue(process, def, info.Field, info, options); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: invalid default %q: %w", info.Name, def, err))
+			continue
+		}
+		if err := validateField(info, options); err != nil {
+			errs = append(errs, fmt.Errorf("field %s: invalid default %q: %w", info.Name, def, err))
+		}
+	}
+	return errorsJoin(errs)
+}
@@ -0,0 +1,165 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// findInfo returns the info of the field named ref, by Go field name or by
+// key, or nil if there is none.
+func findInfo(infos []varInfo, ref string) *varInfo {
+	for i := range infos {
+		if infos[i].Name == ref || infos[i].Key == strings.ToUpper(ref) {
+			return &infos[i]
+		}
+	}
+	return nil
+}
+
+// requiredIf parses the required_if tag of info into the field it refers to
+// and the value that field must hold for info to be required.
+func requiredIf(info varInfo, infos []varInfo, options Options) (ref *varInfo, want reflect.Value, err error) {
+	kv := strings.SplitN(info.Tags.Get("required_if"), "=", 2)
+	if len(kv) != 2 {
+		return nil, want, fmt.Errorf("field %s: required_if must be of the form FIELD=VALUE", info.Name)
+	}
+	name, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+	if ref = findInfo(infos, name); ref == nil || ref.Name == info.Name {
+		return nil, want, fmt.Errorf("field %s: required_if refers to unknown field %s", info.Name, name)
+	}
+	want = reflect.New(ref.Field.Type()).Elem()
+	if err := processField(value, want, ref.Tags, options); err != nil {
+		return nil, want, fmt.Errorf("field %s: required_if value %q: %w", info.Name, value, err)
+	}
+	return ref, want, nil
+}
+
+// checkRequiredIfTags verifies the required_if tags of infos before any
+// field is processed.
+func checkRequiredIfTags(infos []varInfo, options Options) error {
+	for _, info := range infos {
+		if info.Tags.Get("required_if") == "" {
+			continue
+		}
+		if _, _, err := requiredIf(info, infos, options); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRequiredIf returns a missing value error for each field whose
+// required_if condition holds once every field has been processed, but that
+// has no value that would satisfy required.
+func checkRequiredIf(infos []varInfo, options Options) []error {
+	var errs []error
+	for _, info := range infos {
+		if info.Tags.Get("required_if") == "" {
+			continue
+		}
+		holds, err := requiredIfHolds(info, infos, options, func(ref varInfo) (reflect.Value, error) {
+			return ref.Field, nil
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !holds {
+			continue
+		}
+		if _, src, err := resolveValue(info, options); err != nil {
+			errs = append(errs, err)
+		} else if !src.satisfiesRequired() {
+			errs = append(errs, fmt.Errorf("required key %s missing value", options.mapKey(info.Key)))
+		}
+	}
+	return errs
+}
+
+// requiredIfHolds reports whether the required_if condition of info holds,
+// taking the value of the field it refers to from current.
+func requiredIfHolds(info varInfo, infos []varInfo, options Options, current func(varInfo) (reflect.Value, error)) (bool, error) {
+	ref, want, err := requiredIf(info, infos, options)
+	if err != nil {
+		return false, err
+	}
+	got, err := current(*ref)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(got.Interface(), want.Interface()), nil
+}
+
+// resolvedField returns the value Process would give the field behind info,
+// without setting it.
+func resolvedField(info varInfo, options Options) (reflect.Value, error) {
+	value, src, err := resolveValue(info, options)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	field := reflect.New(info.Field.Type()).Elem()
+	switch src {
+	case sourceSeed:
+		field.Set(info.Field)
+		return field, nil
+	case sourceZero:
+		if info.Tags.Get("default") != zeroDefault {
+			field.Set(info.Field)
+		}
+		return field, nil
+	}
+
+	if options.ExpandVars {
+		value = expandVars(value, options.lookup)
+	}
+	if options.TransformFunc != nil {
+		value = options.TransformFunc(info.Key, value)
+	}
+	process := processField
+	if src == sourceComputed {
+		process = setNow
+	}
+	return field, process(value, field, info.Tags, options)
+}
+
+// checkGroups returns an error for each group tag shared by fields of which
+// not exactly one was set, as reported by set, naming the keys involved.
+func checkGroups(infos []varInfo, set []bool, options Options) []error {
+	members := make(map[string][]string)
+	present := make(map[string][]string)
+	for i, info := range infos {
+		group := info.Tags.Get("group")
+		if group == "" {
+			continue
+		}
+		key := options.mapKey(info.Key)
+		members[group] = append(members[group], key)
+		if set[i] {
+			present[group] = append(present[group], key)
+		}
+	}
+
+	groups := make([]string, 0, len(members))
+	for group := range members {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	var errs []error
+	for _, group := range groups {
+		switch len(present[group]) {
+		case 1:
+		case 0:
+			errs = append(errs, fmt.Errorf("group %s: one of %s must be set", group, strings.Join(members[group], ", ")))
+		default:
+			errs = append(errs, fmt.Errorf("group %s: only one of %s may be set", group, strings.Join(present[group], ", ")))
+		}
+	}
+	return errs
+}
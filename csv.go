@@ -0,0 +1,46 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"encoding/csv"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+//nolint:gochecknoglobals
+var csvRecordsType = reflect.TypeOf([][]string(nil))
+
+// processCSV parses value as CSV records into a [][]string field. The
+// csvheader tag set to "skip" drops the first record, and csvcomment names a
+// character that starts comment lines.
+func processCSV(value string, field reflect.Value, tags reflect.StructTag) error {
+	if field.Type() != csvRecordsType {
+		return fmt.Errorf("format csv requires a [][]string field, got %s", field.Type())
+	}
+
+	r := csv.NewReader(strings.NewReader(value))
+	r.FieldsPerRecord = -1
+	if comment := tags.Get("csvcomment"); comment != "" {
+		c, size := utf8.DecodeRuneInString(comment)
+		if size != len(comment) {
+			return fmt.Errorf("csvcomment must be a single character, got %q", comment)
+		}
+		r.Comment = c
+	}
+
+	records, err := r.ReadAll()
+	if err != nil {
+		return err
+	}
+	if tags.Get("csvheader") == "skip" && len(records) > 0 {
+		records = records[1:]
+	}
+
+	field.Set(reflect.ValueOf(records))
+	return nil
+}
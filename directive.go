@@ -0,0 +1,63 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Directive is a zero-size marker that lets a specification declare its own
+// processing options. Embed it with an envconfig tag holding comma-separated
+// settings:
+//
+//	type Specification struct {
+//		envconfig.Directive `envconfig:"prefix=myapp,split_words=true"`
+//		Port int
+//	}
+//
+// The supported settings are prefix and split_words. They only take effect
+// when the caller passes an empty prefix, so an explicit prefix always wins.
+type Directive struct{}
+
+//nolint:gochecknoglobals
+var directiveType = reflect.TypeOf(Directive{})
+
+// applyDirective merges the settings of a Directive field in t, if any, into
+// options.
+func applyDirective(t reflect.Type, options Options) (Options, error) {
+	if options.Prefix != "" {
+		return options, nil
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		ftype := t.Field(i)
+		if ftype.Type != directiveType {
+			continue
+		}
+
+		tag := ftype.Tag.Get("envconfig")
+		if tag == "" {
+			continue
+		}
+		for _, setting := range strings.Split(tag, ",") {
+			kv := strings.SplitN(setting, "=", 2)
+			if len(kv) != 2 {
+				return options, fmt.Errorf("invalid directive setting %q", setting)
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "prefix":
+				options.Prefix = strings.TrimSpace(kv[1])
+			case "split_words":
+				options.SplitWords = options.SplitWords || isTrue(strings.TrimSpace(kv[1]))
+			default:
+				return options, fmt.Errorf("unknown directive setting %q", kv[0])
+			}
+		}
+	}
+
+	return options, nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ProcessFromFile populates the specified struct from a .env file of
+// KEY=VALUE lines, falling back to the environment for keys the file does
+// not set. Blank lines and lines starting with # are skipped, a leading
+// "export " is allowed, and values may be wrapped in single or double quotes.
+func ProcessFromFile(prefix string, spec interface{}, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	env, err := parseDotEnv(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return ProcessX(spec, Options{
+		Prefix: prefix,
+		LookupFunc: func(key string) (string, bool) {
+			if v, ok := env[key]; ok {
+				return v, true
+			}
+			return lookupEnv(key)
+		},
+	})
+}
+
+// parseDotEnv reads the variables set in a .env file.
+func parseDotEnv(r io.Reader) (map[string]string, error) {
+	env := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		kv := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(kv[0])
+		if len(kv) != 2 || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE, got %q", n, line)
+		}
+		env[key] = unquoteDotEnv(strings.TrimSpace(kv[1]))
+	}
+	return env, scanner.Err()
+}
+
+// unquoteDotEnv strips one pair of matching single or double quotes.
+func unquoteDotEnv(value string) string {
+	if len(value) >= 2 {
+		if q := value[0]; (q == '"' || q == '\'') && value[len(value)-1] == q {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
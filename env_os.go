@@ -1,5 +1,3 @@
-// +build appengine go1.5
-
 package envconfig
 
 import "os"
@@ -6,11 +6,14 @@ package envconfig
 
 import (
 	"encoding"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,16 +22,172 @@ import (
 // ErrInvalidSpecification indicates that a specification is of the wrong type.
 var ErrInvalidSpecification = errors.New("specification must be a struct pointer")
 
+// ErrTimeout indicates that ProcessTimeout gave up waiting for processing to
+// complete.
+var ErrTimeout = errors.New("processing timed out")
+
 //nolint:gochecknoglobals
 var (
 	gatherRegexp  = regexp.MustCompile("([^A-Z]+|[A-Z]+[^A-Z]+|[A-Z]+)")
 	acronymRegexp = regexp.MustCompile("([A-Z]+)([A-Z][^A-Z]+)")
+	urlValuesType = reflect.TypeOf(url.Values{})
+	boolWords     = map[string]bool{
+		"yes": true, "on": true, "enabled": true,
+		"no": false, "off": false, "disabled": false,
+	}
 )
 
 // Options is used with ProcessX() when you want to pass custom parameters
 type Options struct {
 	Prefix     string // sets prefix for env vars
 	SplitWords bool   // use split_words = true by default
+
+	// CacheDecoders reuses the result of a Decoder for fields of the same
+	// type that see the same value, including across calls. Decoders must be
+	// pure functions of their input for this to be safe, and the cached
+	// result is shallow-copied into each field. Only the most recently used
+	// results are kept, so the cache stays bounded across reloads.
+	CacheDecoders bool
+
+	// LastWins reverses the precedence of the keys consulted for a field.
+	// By default the first key that is set wins: the prefixed key, then the
+	// alternate key from the envconfig tag. With LastWins the alternate key
+	// overrides the prefixed key when both are set. Defaults always have the
+	// lowest priority, whichever direction is chosen.
+	LastWins bool
+
+	// NilEmptyStructs leaves a nil pointer-to-struct field nil unless at
+	// least one of the variables beneath it is set in the environment.
+	// Defaults alone do not cause the struct to be allocated.
+	NilEmptyStructs bool
+
+	// WarnCaseVariants scans the environment for variables under the prefix
+	// whose names differ only by case, such as APP_HOST and app_host, since
+	// only the exact match is ever read. Each group of variants is passed to
+	// CaseVariantFunc, or logged when it is nil.
+	WarnCaseVariants bool
+	CaseVariantFunc  func(keys []string)
+
+	// LookupFunc, when set, replaces the environment as the source of every
+	// value read while processing, including alternate and negate keys.
+	// WarnCaseVariants is skipped, since there is no environment to scan.
+	LookupFunc func(key string) (string, bool)
+
+	// CaseInsensitiveValues makes bool parsing and enum tag matching ignore
+	// case across the whole specification. Enum values are stored in the
+	// spelling declared in the tag.
+	CaseInsensitiveValues bool
+
+	// AutoJSON parses the value of a slice or map field as JSON when it
+	// starts with '[' or '{', and falls back to the comma-separated form
+	// otherwise.
+	AutoJSON bool
+
+	// KeyMapper rewrites the key derived for each field just before it is
+	// looked up, for example to adapt to another naming scheme. The mapped
+	// key is also the base of _FILE and indexed variables. Alternate keys
+	// from the envconfig tag and usage output are not affected.
+	KeyMapper func(key string) string
+
+	// AutoSeparator splits slice values on newlines if there are any, else
+	// on semicolons if there are any, else on commas. Only the first
+	// separator detected is used, so "a;b,c" yields "a" and "b,c".
+	AutoSeparator bool
+
+	// MaxIndex caps the indexes probed for slice fields with the indexed
+	// tag. Sparse slices are sized to the highest index found, so a large
+	// limit may allocate a large slice. It defaults to DefaultMaxIndex.
+	MaxIndex int
+
+	// OmitSecrets makes ExportToEnvX leave Password fields and fields
+	// tagged sensitive out of the environment instead of exporting their
+	// actual values.
+	OmitSecrets bool
+
+	// FileFallback reads a field whose variable is unset from the file
+	// named by the variable with _FILE appended, as in DB_PASSWORD_FILE, the
+	// convention for secrets mounted into containers. A trailing newline is
+	// dropped.
+	FileFallback bool
+
+	// AllErrors makes ProcessX carry on past fields that fail to parse or
+	// validate and return every problem found as Errors, instead of
+	// stopping at the first one.
+	AllErrors bool
+
+	// Now returns the current time, for default:"now" on time.Time fields
+	// and the future and past validation rules. It defaults to time.Now and
+	// can be replaced to make tests deterministic.
+	Now func() time.Time
+
+	// ExpandVars replaces ${NAME} and $NAME references in each value,
+	// defaults included, before it is converted. References are resolved
+	// through LookupFunc or the environment, and expand to nothing when
+	// unset. A malformed reference such as ${} is left as written.
+	ExpandVars bool
+
+	// Strict fails processing when the environment holds variables that
+	// start with the prefix but that no field reads, which usually means a
+	// typo such as MYAPP_PROT for MYAPP_PORT. Alternate keys, _FILE variants
+	// under FileFallback, indexed elements and negate keys all count as
+	// read. It has no effect without a prefix or when LookupFunc is set,
+	// since the environment is then not the source.
+	Strict bool
+
+	// TagName is the struct tag that overrides the key of a field, in place
+	// of envconfig, so that structs annotated for another library can be
+	// reused. Anything after a comma in that tag, such as the options some
+	// libraries put there, is ignored. The other tags keep their names.
+	TagName string
+
+	// TransformFunc, when set, rewrites every value before it is converted,
+	// defaults included, for example to trim or lowercase it. It receives
+	// the key of the field, so it can treat fields differently, and runs
+	// after ExpandVars.
+	TransformFunc func(key, raw string) string
+
+	// seeded holds the fields set by a JSON seed, which are left alone
+	// unless their variable is set.
+	seeded map[seedField]bool
+
+	// only, when set, restricts processing to the fields it accepts.
+	only func(varInfo) bool
+}
+
+// now returns the current time from Now, or from time.Now when it is nil.
+func (o Options) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// altKey returns the key override for a field from the tag named by TagName,
+// or from the envconfig tag when it is empty.
+func (o Options) altKey(tag reflect.StructTag) string {
+	if o.TagName == "" {
+		return strings.ToUpper(tag.Get("envconfig"))
+	}
+	alt := strings.SplitN(tag.Get(o.TagName), ",", 2)[0]
+	return strings.ToUpper(strings.TrimSpace(alt))
+}
+
+// mapKey returns key as rewritten by KeyMapper, or unchanged when it is nil.
+func (o Options) mapKey(key string) string {
+	if o.KeyMapper != nil {
+		return o.KeyMapper(key)
+	}
+	return key
+}
+
+// lookup reads key from LookupFunc, or from the environment when it is nil.
+func (o Options) lookup(key string) (string, bool) {
+	if o.LookupFunc != nil {
+		return o.LookupFunc(key)
+	}
+	// `os.Getenv` cannot differentiate between an explicitly set empty value
+	// and an unset value, so `os.LookupEnv` is used instead.
+	return lookupEnv(key)
 }
 
 // A ParseError occurs when an environment variable cannot be converted to
@@ -67,6 +226,56 @@ type varInfo struct {
 	Key   string
 	Field reflect.Value
 	Tags  reflect.StructTag
+
+	// Setter is the method named by the setter tag, bound to the struct
+	// that holds the field. It is the zero Value when the tag is absent.
+	Setter reflect.Value
+
+	// Allocated holds the nil struct pointers, from the outermost inwards,
+	// that were allocated while gathering this variable.
+	Allocated []reflect.Value
+
+	// Pattern is the compiled pattern tag, or nil when it is absent.
+	Pattern *regexp.Regexp
+
+	// Sensitive is set by the sensitive tag. Usage output and Marshal
+	// print such values as sensitiveMask.
+	Sensitive bool
+}
+
+// sensitiveMask replaces the values of sensitive fields in output.
+const sensitiveMask = "****"
+
+// newParseError returns a ParseError for value, read from key into the field
+// behind info. The value of a sensitive field is masked, both in Value and
+// wherever err repeats it.
+func newParseError(info varInfo, key, value string, err error) *ParseError {
+	if info.Sensitive && value != "" {
+		err = maskedError{err: err, value: value}
+		value = sensitiveMask
+	}
+	return &ParseError{
+		KeyName:   key,
+		FieldName: info.Name,
+		TypeName:  info.Field.Type().String(),
+		Value:     value,
+		Err:       err,
+	}
+}
+
+// maskedError hides value wherever it appears in the message of err, which
+// stays available through Unwrap.
+type maskedError struct {
+	err   error
+	value string
+}
+
+func (e maskedError) Error() string {
+	return strings.Replace(e.err.Error(), e.value, sensitiveMask, -1)
+}
+
+func (e maskedError) Unwrap() error {
+	return e.err
 }
 
 // GatherInfo gathers information about the specified struct
@@ -82,15 +291,21 @@ func gatherInfo(spec interface{}, options Options) ([]varInfo, error) {
 	}
 	typeOfSpec := s.Type()
 
+	options, err := applyDirective(typeOfSpec, options)
+	if err != nil {
+		return nil, err
+	}
+
 	// over allocate an info array, we will extend if needed later
 	infos := make([]varInfo, 0, s.NumField())
 	for i := 0; i < s.NumField(); i++ {
 		f := s.Field(i)
 		ftype := typeOfSpec.Field(i)
-		if !f.CanSet() || isTrue(ftype.Tag.Get("ignored")) {
+		if !f.CanSet() || isTrue(ftype.Tag.Get("ignored")) || ftype.Type == directiveType {
 			continue
 		}
 
+		var allocated []reflect.Value
 		for f.Kind() == reflect.Ptr {
 			if f.IsNil() {
 				if f.Type().Elem().Kind() != reflect.Struct {
@@ -99,16 +314,66 @@ func gatherInfo(spec interface{}, options Options) ([]varInfo, error) {
 				}
 				// nil pointer to struct: create a zero instance
 				f.Set(reflect.New(f.Type().Elem()))
+				allocated = append(allocated, f)
 			}
 			f = f.Elem()
 		}
 
+		if err := checkSortTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkDedupTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkValidateTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkIndexedTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkPipeTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkRangeTags(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkOneOfTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkMaxBytesTag(ftype); err != nil {
+			return nil, err
+		}
+		if err := checkTimeTags(ftype); err != nil {
+			return nil, err
+		}
+		if ftype.Tag.Get("negate") != "" && ftype.Type.Kind() != reflect.Bool {
+			return nil, fmt.Errorf("field %s: negate requires a bool field, got %s", ftype.Name, ftype.Type)
+		}
+		if ftype.Tag.Get("emptyvalue") != "" && !isCollection(ftype.Type) {
+			return nil, fmt.Errorf("field %s: emptyvalue requires a slice or map field, got %s", ftype.Name, ftype.Type)
+		}
+
 		// Capture information about the config variable
 		info := varInfo{
 			Name:  ftype.Name,
 			Field: f,
 			Tags:  ftype.Tag,
-			Alt:   strings.ToUpper(ftype.Tag.Get("envconfig")),
+			Alt:   options.altKey(ftype.Tag),
+
+			Allocated: allocated,
+			Sensitive: isTrue(ftype.Tag.Get("sensitive")),
+		}
+
+		if info.Pattern, err = compilePattern(ftype); err != nil {
+			return nil, err
+		}
+
+		if name := ftype.Tag.Get("setter"); name != "" {
+			m, err := setterMethod(s, name)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", ftype.Name, err)
+			}
+			info.Setter = m
 		}
 
 		// Default to the field name as the env var name (will be upcased)
@@ -143,9 +408,10 @@ func gatherInfo(spec interface{}, options Options) ([]varInfo, error) {
 
 		if f.Kind() == reflect.Struct {
 			// honor Decode if present
-			if decoderFrom(f) == nil && setterFrom(f) == nil && textUnmarshaler(f) == nil && binaryUnmarshaler(f) == nil {
+			if decoderFrom(f) == nil && setterFrom(f) == nil && textUnmarshaler(f) == nil && binaryUnmarshaler(f) == nil && f.Type() != ipNetType {
+				// embedded and squashed structs share the parent's prefix
 				innerPrefix := options.Prefix
-				if !ftype.Anonymous {
+				if !ftype.Anonymous && !isTrue(ftype.Tag.Get("squash")) {
 					innerPrefix = info.Key
 				}
 
@@ -153,10 +419,14 @@ func gatherInfo(spec interface{}, options Options) ([]varInfo, error) {
 				embeddedInfos, err := gatherInfo(embeddedPtr, Options{
 					Prefix:     innerPrefix,
 					SplitWords: options.SplitWords,
+					TagName:    options.TagName,
 				})
 				if err != nil {
 					return nil, err
 				}
+				for i := range embeddedInfos {
+					embeddedInfos[i].Allocated = append(allocated[:len(allocated):len(allocated)], embeddedInfos[i].Allocated...)
+				}
 				infos = append(infos[:len(infos)-1], embeddedInfos...)
 
 				continue
@@ -168,18 +438,15 @@ func gatherInfo(spec interface{}, options Options) ([]varInfo, error) {
 
 // CheckDisallowed checks that no environment variables with the prefix are set
 // that we don't know how or want to parse. This is likely only meaningful with
-// a non-empty prefix.
+// a non-empty prefix. Indexed elements and negate keys count as known, as
+// they do for Options.Strict.
 func CheckDisallowed(prefix string, spec interface{}) error {
-	infos, err := gatherInfo(spec, Options{Prefix: prefix})
+	options := Options{Prefix: prefix}
+	infos, err := gatherInfo(spec, options)
 	if err != nil {
 		return err
 	}
 
-	vars := make(map[string]struct{})
-	for _, info := range infos {
-		vars[info.Key] = struct{}{}
-	}
-
 	if prefix != "" {
 		prefix = strings.ToUpper(prefix) + "_"
 	}
@@ -189,7 +456,7 @@ func CheckDisallowed(prefix string, spec interface{}) error {
 			continue
 		}
 		v := strings.SplitN(env, "=", 2)[0]
-		if _, found := vars[v]; !found {
+		if !knownKey(v, infos, options) {
 			return fmt.Errorf("unknown environment variable %s", v)
 		}
 	}
@@ -197,11 +464,81 @@ func CheckDisallowed(prefix string, spec interface{}) error {
 	return nil
 }
 
+// Errors is returned by ProcessAll, and by ProcessX with Options.AllErrors,
+// with one error for each field at fault.
+type Errors []error
+
+// Error lists each error on its own line.
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the individual errors, so that errors.As can pick out a
+// *ParseError. Only Go 1.20 and newer follow it; As and Is cover the older
+// releases.
+func (errs Errors) Unwrap() []error {
+	return errs
+}
+
+// As finds the first of the errors that matches target, as errors.As does.
+func (errs Errors) As(target interface{}) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Is reports whether any of the errors matches target, as errors.Is does.
+func (errs Errors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
 // Process populates the specified struct based on environment variables
 func Process(prefix string, spec interface{}) error {
 	return ProcessX(spec, Options{Prefix: prefix})
 }
 
+// ProcessAll is like Process but reports every misconfigured field at once,
+// as Errors, rather than stopping at the first.
+func ProcessAll(prefix string, spec interface{}) error {
+	return ProcessX(spec, Options{Prefix: prefix, AllErrors: true})
+}
+
+// ProcessWithConsumed is like Process but also returns the sorted names of
+// the environment variables that were read and found set, including
+// alternate keys, so they can be compared against what a deployment provides.
+func ProcessWithConsumed(prefix string, spec interface{}) ([]string, error) {
+	consumed := make(map[string]bool)
+	err := ProcessX(spec, Options{
+		Prefix: prefix,
+		LookupFunc: func(key string) (string, bool) {
+			value, ok := lookupEnv(key)
+			if ok {
+				consumed[key] = true
+			}
+			return value, ok
+		},
+	})
+
+	keys := make([]string, 0, len(consumed))
+	for key := range consumed {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, err
+}
+
 // ProcessX populates the specified struct based on environment variables.
 // This func uses the Options values to configure how the struct is processed
 func ProcessX(spec interface{}, options Options) error {
@@ -210,44 +547,255 @@ func ProcessX(spec interface{}, options Options) error {
 		return err
 	}
 
+	if err := checkRequiredIfTags(infos, options); err != nil {
+		return err
+	}
+
+	if options.WarnCaseVariants && options.LookupFunc == nil {
+		warnCaseVariants(options)
+	}
+
 	var errs []error
+	set := make([]bool, len(infos))
 
-	for _, info := range infos {
+	for i, info := range infos {
+		if options.only != nil && !options.only(info) {
+			continue
+		}
 
-		// `os.Getenv` cannot differentiate between an explicitly set empty value
-		// and an unset value. `os.LookupEnv` is preferred to `syscall.Getenv`,
-		// but it is only available in go1.5 or newer. We're using Go build tags
-		// here to use os.LookupEnv for >=go1.5
-		value, ok := lookupEnv(info.Key)
-		if !ok && info.Alt != "" {
-			value, ok = lookupEnv(info.Alt)
+		var missing bool
+		set[i], missing, err = processInfo(info, options)
+		if missing && set[i] {
+			err = fmt.Errorf("required key %s set to empty value", options.mapKey(info.Key))
+		} else if missing {
+			err = fmt.Errorf("required key %s missing value", options.mapKey(info.Key))
+		}
+		if err != nil {
+			if !missing && !options.AllErrors {
+				return err
+			}
+			errs = append(errs, err)
 		}
+	}
+
+	if options.only == nil {
+		// these look across fields, so they need every field processed
+		errs = append(errs, checkRequiredIf(infos, options)...)
+		errs = append(errs, checkGroups(infos, set, options)...)
+	}
 
-		def := info.Tags.Get("default")
-		if def != "" && !ok {
-			value = def
+	if options.NilEmptyStructs {
+		resetEmptyStructs(infos, set)
+	}
+
+	if options.Strict && options.LookupFunc == nil {
+		if err := checkStrict(spec, infos, options); err != nil {
+			if len(errs) == 0 && !options.AllErrors {
+				return err
+			}
+			errs = append(errs, err)
 		}
+	}
+
+	if options.AllErrors && len(errs) > 0 {
+		return Errors(errs)
+	}
+	if len(errs) > 0 {
+		return errorsJoin(errs)
+	}
+
+	return runValidators(reflect.ValueOf(spec))
+}
+
+// processInfo sets the field behind info from the environment. It reports
+// whether a variable was set, and whether a required one was missing; both
+// together mean the variable was set but empty under required_notempty.
+func processInfo(info varInfo, options Options) (set, missing bool, err error) {
+	if err := checkRemovedKeys(info, options); err != nil {
+		return false, false, err
+	}
+
+	if negated, err := lookupNegate(info, options); err != nil {
+		return false, false, err
+	} else if negated {
+		info.Field.SetBool(false)
+		return true, false, nil
+	}
+
+	if found, err := processIndexed(info, options); err != nil {
+		return false, false, err
+	} else if found {
+		return true, false, validateField(info, options)
+	}
 
-		req := info.Tags.Get("required")
-		if !ok && def == "" {
-			if isTrue(req) {
-				errs = append(errs, fmt.Errorf("required key %s missing value", info.Key))
+	value, src, err := resolveValue(info, options)
+	if err != nil {
+		return false, false, err
+	}
+	ok := src.fromEnvironment()
+
+	if missing, empty := checkRequired(info, value, src); missing {
+		return empty, true, nil
+	}
+	switch src {
+	case sourceSeed:
+		return false, false, nil
+	case sourceZero:
+		setZero(info)
+		return false, false, nil
+	}
+
+	if options.ExpandVars {
+		value = expandVars(value, options.lookup)
+	}
+	if options.TransformFunc != nil {
+		value = options.TransformFunc(info.Key, value)
+	}
+
+	process := processField
+	switch {
+	case src == sourceComputed:
+		process = setNow
+	case info.Setter.IsValid():
+		process = info.callSetter
+	case options.CacheDecoders && decoderFrom(info.Field) != nil:
+		process = processFieldCached
+	}
+
+	if err := parseValue(process, value, info.Field, info, options); err != nil {
+		return ok, false, newParseError(info, options.mapKey(info.Key), value, err)
+	}
+
+	return ok, false, validateField(info, options)
+}
+
+// parseValue converts value into field with process and applies the checks
+// the tags of info ask for: maxbytes on the raw value, then range, pattern
+// and oneof on the result. field is info.Field or, for indexed variables,
+// one element of it.
+func parseValue(
+	process func(string, reflect.Value, reflect.StructTag, Options) error,
+	value string, field reflect.Value, info varInfo, options Options,
+) error {
+	if err := checkMaxBytes(value, info.Tags); err != nil {
+		return err
+	}
+	if err := process(value, field, info.Tags, options); err != nil {
+		return err
+	}
+	if err := checkRange(field, info.Tags); err != nil {
+		return err
+	}
+	if err := checkPattern(field, info.Pattern); err != nil {
+		return err
+	}
+	return checkOneOf(field, info.Tags)
+}
+
+// resetEmptyStructs sets the struct pointers allocated while gathering back
+// to nil, unless a variable beneath them was set.
+func resetEmptyStructs(infos []varInfo, set []bool) {
+	keep := make(map[uintptr]bool)
+	for i, info := range infos {
+		if set[i] {
+			for _, ptr := range info.Allocated {
+				keep[ptr.Addr().Pointer()] = true
+			}
+		}
+	}
+	for _, info := range infos {
+		for _, ptr := range info.Allocated {
+			if !keep[ptr.Addr().Pointer()] {
+				ptr.Set(reflect.Zero(ptr.Type()))
+				break
 			}
-			continue
 		}
+	}
+}
 
-		if err := processField(value, info.Field); err != nil {
-			return &ParseError{
-				KeyName:   info.Key,
-				FieldName: info.Name,
-				TypeName:  info.Field.Type().String(),
-				Value:     value,
-				Err:       err,
+// lookupKeys returns the keys consulted for info, in order, with the first
+// one rewritten by the KeyMapper of options.
+func lookupKeys(info varInfo, options Options) []string {
+	keys := []string{options.mapKey(info.Key)}
+	if info.Alt != "" && info.Alt != info.Key {
+		keys = append(keys, info.Alt)
+	}
+	return keys
+}
+
+// lookupValue resolves the value of info from the environment, honoring the
+// precedence chosen in options, and reports which key it came from.
+func lookupValue(info varInfo, options Options) (value string, src valueSource, ok bool) {
+	for i, key := range lookupKeys(info, options) {
+		if v, found := options.lookup(key); found {
+			value, ok = v, true
+			src = sourceEnv
+			if i > 0 {
+				src = sourceAlt
 			}
+			if !options.LastWins {
+				break
+			}
+		}
+	}
+	return value, src, ok
+}
+
+// checkRemovedKeys returns an error if any of the legacy keys listed in the
+// deprecated_removed tag of info is still set, naming the key that replaced
+// it.
+func checkRemovedKeys(info varInfo, options Options) error {
+	tag := info.Tags.Get("deprecated_removed")
+	if tag == "" {
+		return nil
+	}
+	for _, key := range strings.Split(tag, ",") {
+		key = strings.ToUpper(strings.TrimSpace(key))
+		if _, ok := options.lookup(key); ok {
+			return fmt.Errorf("environment variable %s is no longer supported, use %s instead", key, options.mapKey(info.Key))
 		}
 	}
+	return nil
+}
+
+// lookupNegate reports whether the variable named by the negate tag of info
+// is set to a true value, which forces the field to false.
+func lookupNegate(info varInfo, options Options) (bool, error) {
+	key := strings.ToUpper(info.Tags.Get("negate"))
+	if key == "" {
+		return false, nil
+	}
+	value, ok := options.lookup(key)
+	if !ok {
+		return false, nil
+	}
+	negated, err := parseBool(value)
+	if err != nil {
+		return false, newParseError(info, key, value, err)
+	}
+	return negated, nil
+}
+
+// ProcessTimeout is the same as Process but returns ErrTimeout if processing
+// does not complete within d, for example because a Decoder is blocked on the
+// network. Processing is not interrupted: the goroutine running it may leak
+// and keep writing to spec after ProcessTimeout returns, so spec must not be
+// used after a timeout.
+func ProcessTimeout(prefix string, spec interface{}, d time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- Process(prefix, spec)
+	}()
 
-	return errorsJoin(errs)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return ErrTimeout
+	}
 }
 
 // MustProcess is the same as Process but panics if an error occurs
@@ -257,9 +805,51 @@ func MustProcess(prefix string, spec interface{}) {
 	}
 }
 
-func processField(value string, field reflect.Value) error {
+func processField(value string, field reflect.Value, tags reflect.StructTag, options Options) error {
 	typ := field.Type()
 
+	if typ.Kind() == reflect.Interface && !field.IsNil() {
+		// decode into the concrete value the field was seeded with
+		elem := field.Elem()
+		if elem.Kind() == reflect.Ptr {
+			return processField(value, elem, tags, options)
+		}
+		v := reflect.New(elem.Type()).Elem()
+		v.Set(elem)
+		if err := processField(value, v, tags, options); err != nil {
+			return err
+		}
+		field.Set(v)
+		return nil
+	}
+
+	if isTrue(tags.Get("trim")) && !splitsValue(field) {
+		value = strings.TrimSpace(value)
+	}
+
+	if pipe := tags.Get("pipe"); pipe != "" && !splitsValue(field) {
+		var err error
+		if value, err = applyPipe(value, pipe); err != nil {
+			return err
+		}
+	}
+
+	if isTime(typ) && (tags.Get("format") != "" || tags.Get("layout") != "" || tags.Get("location") != "") {
+		return processTime(value, field, tags)
+	}
+
+	if tags.Get("format") == "csv" {
+		return processCSV(value, field, tags)
+	}
+
+	if isNet(typ) {
+		return processNet(value, field)
+	}
+
+	if t := tagSetterFrom(field); t != nil {
+		return t.setWithTags(value, tags)
+	}
+
 	decoder := decoderFrom(field)
 	if decoder != nil {
 		return decoder.Decode(value)
@@ -286,6 +876,39 @@ func processField(value string, field reflect.Value) error {
 		field = field.Elem()
 	}
 
+	if sentinel := tags.Get("emptyvalue"); sentinel != "" && value == sentinel {
+		switch typ.Kind() {
+		case reflect.Slice:
+			field.Set(reflect.MakeSlice(typ, 0, 0))
+			return nil
+		case reflect.Map:
+			field.Set(reflect.MakeMap(typ))
+			return nil
+		}
+	}
+
+	if isBytes(typ) {
+		return processBytes(value, field, tags)
+	}
+
+	if options.AutoJSON && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Map) && looksLikeJSON(value) {
+		v := reflect.New(typ)
+		if err := json.Unmarshal([]byte(value), v.Interface()); err != nil {
+			return err
+		}
+		field.Set(v.Elem())
+		return nil
+	}
+
+	if typ == urlValuesType {
+		vals, err := url.ParseQuery(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(vals))
+		return nil
+	}
+
 	switch typ.Kind() {
 	case reflect.String:
 		field.SetString(value)
@@ -298,6 +921,8 @@ func processField(value string, field reflect.Value) error {
 			var d time.Duration
 			d, err = time.ParseDuration(value)
 			val = int64(d)
+		} else if units, value := unitsFor(value, tags); units != "" {
+			val, err = parseIntUnits(value, units, typ.Bits())
 		} else {
 			val, err = strconv.ParseInt(value, 0, typ.Bits())
 		}
@@ -307,13 +932,24 @@ func processField(value string, field reflect.Value) error {
 
 		field.SetInt(val)
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		val, err := strconv.ParseUint(value, 0, typ.Bits())
+		var (
+			val uint64
+			err error
+		)
+		if units, value := unitsFor(value, tags); units != "" {
+			val, err = parseUintUnits(value, units, typ.Bits())
+		} else {
+			val, err = strconv.ParseUint(value, 0, typ.Bits())
+		}
 		if err != nil {
 			return err
 		}
 		field.SetUint(val)
 	case reflect.Bool:
-		val, err := strconv.ParseBool(value)
+		if options.CaseInsensitiveValues {
+			value = strings.ToLower(value)
+		}
+		val, err := parseBool(value)
 		if err != nil {
 			return err
 		}
@@ -324,32 +960,67 @@ func processField(value string, field reflect.Value) error {
 			return err
 		}
 		field.SetFloat(val)
-	case reflect.Slice:
-		vals := strings.Split(value, ",")
-		sl := reflect.MakeSlice(typ, len(vals), len(vals))
+	case reflect.Slice, reflect.Array:
+		sep := listDelimiter(tags)
+		if options.AutoSeparator && tags.Get("delimiter") == "" {
+			sep = detectSeparator(value)
+		}
+		vals := strings.Split(value, sep)
+		if strings.TrimSpace(strings.Replace(value, sep, "", -1)) == "" {
+			// nothing but blanks and separators: an empty list, like a map
+			vals = nil
+		}
+		if sep == "\n" {
+			for i := range vals {
+				vals[i] = strings.TrimSuffix(vals[i], "\r")
+			}
+		}
+		var sl reflect.Value
+		if typ.Kind() == reflect.Array {
+			if len(vals) != typ.Len() {
+				return fmt.Errorf("expected %d elements, got %d", typ.Len(), len(vals))
+			}
+			sl = reflect.New(typ).Elem()
+		} else {
+			sl = reflect.MakeSlice(typ, len(vals), len(vals))
+		}
+		nonEmpty := isTrue(tags.Get("nonemptyitems"))
 		for i, val := range vals {
-			err := processField(val, sl.Index(i))
+			if nonEmpty && strings.TrimSpace(val) == "" {
+				return fmt.Errorf("element %d: empty item", i)
+			}
+			err := processField(val, sl.Index(i), tags, options)
 			if err != nil {
-				return err
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		if typ.Kind() == reflect.Slice {
+			sortSlice(sl, tags.Get("sort"))
+			if isTrue(tags.Get("dedup")) {
+				sl = dedupSlice(sl)
 			}
 		}
 		field.Set(sl)
 	case reflect.Map:
 		mp := reflect.MakeMap(typ)
 		if len(strings.TrimSpace(value)) != 0 {
-			pairs := strings.Split(value, ",")
+			pairs := strings.Split(value, listDelimiter(tags))
 			for _, pair := range pairs {
-				kvpair := strings.Split(pair, ":")
+				kvpair := strings.Split(pair, mapSeparator(tags))
+				if len(kvpair) == 1 && typ.Elem().Kind() == reflect.Bool {
+					// a listed key on its own means true, as in a set
+					kvpair = append(kvpair, "true")
+				}
 				if len(kvpair) != 2 {
 					return fmt.Errorf("invalid map item: %q", pair)
 				}
 				k := reflect.New(typ.Key()).Elem()
-				err := processField(kvpair[0], k)
+				err := processField(kvpair[0], k, tags, options)
 				if err != nil {
 					return err
 				}
 				v := reflect.New(typ.Elem()).Elem()
-				err = processField(kvpair[1], v)
+				err = processField(kvpair[1], v, tags, options)
 				if err != nil {
 					return err
 				}
@@ -394,6 +1065,67 @@ func binaryUnmarshaler(field reflect.Value) (b encoding.BinaryUnmarshaler) {
 	return b
 }
 
+// listDelimiter returns the delimiter between list or map elements set by
+// the delimiter tag, defaulting to a comma.
+func listDelimiter(tags reflect.StructTag) string {
+	if sep := tags.Get("delimiter"); sep != "" {
+		return sep
+	}
+	return ","
+}
+
+// mapSeparator returns the separator between map keys and values set by the
+// separator tag, defaulting to a colon.
+func mapSeparator(tags reflect.StructTag) string {
+	if sep := tags.Get("separator"); sep != "" {
+		return sep
+	}
+	return ":"
+}
+
+// detectSeparator picks the list separator used in value, preferring a
+// newline, then a semicolon, then a comma.
+func detectSeparator(value string) string {
+	for _, sep := range []string{"\n", ";"} {
+		if strings.Contains(value, sep) {
+			return sep
+		}
+	}
+	return ","
+}
+
+// isCollection reports whether t, or the type it points to, is a slice or a
+// map.
+func isCollection(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Map
+}
+
+// isZero reports whether v holds the zero value of its type.
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
+// looksLikeJSON reports whether value starts like a JSON array or object.
+func looksLikeJSON(value string) bool {
+	value = strings.TrimSpace(value)
+	return strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{")
+}
+
+// parseBool is strconv.ParseBool extended with the words in boolWords, which
+// are matched case-insensitively.
+func parseBool(value string) (bool, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		if word, ok := boolWords[strings.ToLower(value)]; ok {
+			return word, nil
+		}
+	}
+	return b, err
+}
+
 func isTrue(s string) bool {
 	b, _ := strconv.ParseBool(s)
 	return b
@@ -1,11 +1,10 @@
-// +build go1.8
-
 package envconfig
 
 import (
 	"errors"
 	"net/url"
 	"os"
+	"reflect"
 	"testing"
 )
 
@@ -66,3 +65,34 @@ func TestParseURLError(t *testing.T) {
 		t.Errorf("expected %q, got %q", expectedUnerlyingError, v.Err)
 	}
 }
+
+func TestParseURLValues(t *testing.T) {
+	var s struct {
+		Params url.Values
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PARAMS", "a=1&b=2&b=3")
+
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal("unexpected error:", err)
+	}
+	if got := s.Params.Get("a"); got != "1" {
+		t.Errorf("expected %q, got %q", "1", got)
+	}
+	if got := s.Params["b"]; len(got) != 2 || got[0] != "2" || got[1] != "3" {
+		t.Errorf("expected %v, got %v", []string{"2", "3"}, got)
+	}
+	if desc := toTypeDescription(reflect.TypeOf(s.Params)); desc != "URL-encoded key/value pairs" {
+		t.Errorf("unexpected type description %q", desc)
+	}
+
+	os.Setenv("ENV_CONFIG_PARAMS", "a=%zz")
+	v, ok := Process("env_config", &s).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError for malformed query")
+	}
+	if v.FieldName != "Params" {
+		t.Errorf("expected %s, got %v", "Params", v.FieldName)
+	}
+}
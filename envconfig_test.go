@@ -5,10 +5,18 @@
 package envconfig
 
 import (
+	"bytes"
+	"encoding"
+	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -785,6 +793,24 @@ func TestCheckDisallowedIgnored(t *testing.T) {
 	}
 }
 
+func TestCheckDisallowedIndexed(t *testing.T) {
+	var s struct {
+		Hosts []string `indexed:"true"`
+		Color bool     `negate:"ENV_CONFIG_NO_COLOR"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOSTS_0", "a")
+	os.Setenv("ENV_CONFIG_HOSTS_1", "b")
+	os.Setenv("ENV_CONFIG_NO_COLOR", "1")
+	if err := CheckDisallowed("env_config", &s); err != nil {
+		t.Errorf("expected no error, got %s", err)
+	}
+	os.Setenv("ENV_CONFIG_HOSTS_X", "c")
+	if err := CheckDisallowed("env_config", &s); err == nil {
+		t.Error("expected error for ENV_CONFIG_HOSTS_X")
+	}
+}
+
 func TestSeveralRequiredFields(t *testing.T) {
 	type RequiredFields struct {
 		Required1 string `required:"true"`
@@ -904,3 +930,2917 @@ func BenchmarkGatherInfo(b *testing.B) {
 		_, _ = gatherInfo(&s, Options{Prefix: "env_config"})
 	}
 }
+
+type countingDecoder struct {
+	Value string
+}
+
+//nolint:gochecknoglobals
+var countingDecoderCalls int
+
+func (c *countingDecoder) Decode(value string) error {
+	countingDecoderCalls++
+	c.Value = "decoded:" + value
+	return nil
+}
+
+func TestCacheDecoders(t *testing.T) {
+	var s struct {
+		First  countingDecoder
+		Second countingDecoder
+		Third  *countingDecoder
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_FIRST", "cached")
+	os.Setenv("ENV_CONFIG_SECOND", "cached")
+	os.Setenv("ENV_CONFIG_THIRD", "cached")
+
+	countingDecoderCalls = 0
+	options := Options{Prefix: "env_config", CacheDecoders: true}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// pointers to structs are dereferenced while gathering, so every field
+	// shares a single cache entry
+	if countingDecoderCalls != 1 {
+		t.Errorf("expected %d decoder call, got %d", 1, countingDecoderCalls)
+	}
+	if s.First.Value != "decoded:cached" || s.Second.Value != "decoded:cached" || s.Third.Value != "decoded:cached" {
+		t.Errorf("expected all fields to be decoded, got %+v", s)
+	}
+}
+
+func TestDecoderCacheBounded(t *testing.T) {
+	var s struct {
+		Value countingDecoder
+	}
+	os.Clearenv()
+	options := Options{Prefix: "env_config", CacheDecoders: true}
+	for i := 0; i < 2*decoderCacheSize; i++ {
+		os.Setenv("ENV_CONFIG_VALUE", fmt.Sprintf("value-%d", i))
+		if err := ProcessX(&s, options); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+	decoderCache.Lock()
+	n := len(decoderCache.values)
+	decoderCache.Unlock()
+	if n > decoderCacheSize {
+		t.Errorf("expected at most %d cached values, got %d", decoderCacheSize, n)
+	}
+
+	// the most recent value is still cached
+	countingDecoderCalls = 0
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if countingDecoderCalls != 0 {
+		t.Errorf("expected the last value to be served from the cache, got %d calls", countingDecoderCalls)
+	}
+}
+
+func TestDecoderCacheTags(t *testing.T) {
+	var s struct {
+		A countingDecoder `pipe:"lower"`
+		B countingDecoder
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_A", "X")
+	os.Setenv("ENV_CONFIG_B", "X")
+	if err := ProcessX(&s, Options{Prefix: "env_config", CacheDecoders: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.A.Value != "decoded:x" || s.B.Value != "decoded:X" {
+		t.Errorf("expected tags to be part of the cache key, got %q and %q", s.A.Value, s.B.Value)
+	}
+}
+
+func TestUnixTimeSlice(t *testing.T) {
+	var s struct {
+		Instant time.Time   `format:"unix"`
+		Events  []time.Time `format:"unix"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_INSTANT", "1700000000")
+	os.Setenv("ENV_CONFIG_EVENTS", "1700000000,1700003600")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if want := time.Unix(1700000000, 0); !s.Instant.Equal(want) {
+		t.Errorf("expected %s, got %s", want, s.Instant)
+	}
+	if len(s.Events) != 2 ||
+		!s.Events[0].Equal(time.Unix(1700000000, 0)) ||
+		!s.Events[1].Equal(time.Unix(1700003600, 0)) {
+		t.Errorf("expected two instants an hour apart, got %v", s.Events)
+	}
+
+	os.Setenv("ENV_CONFIG_EVENTS", "1700000000,soon")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "Events" {
+		t.Errorf("expected %s, got %v", "Events", v.FieldName)
+	}
+	if !strings.HasPrefix(v.Err.Error(), "element 1: ") {
+		t.Errorf("expected error to name element 1, got %q", v.Err)
+	}
+}
+
+func TestTimeLayout(t *testing.T) {
+	var s struct {
+		Start    time.Time   `envconfig:"START" layout:"2006-01-02"`
+		Local    *time.Time  `layout:"2006-01-02 15:04" location:"America/New_York"`
+		Holidays []time.Time `layout:"2006-01-02"`
+		Default  time.Time
+	}
+	os.Clearenv()
+	os.Setenv("START", "2024-03-01")
+	os.Setenv("ENV_CONFIG_LOCAL", "2024-03-01 09:30")
+	os.Setenv("ENV_CONFIG_HOLIDAYS", "2024-12-25,2024-12-26")
+	os.Setenv("ENV_CONFIG_DEFAULT", "2024-03-01T09:30:00Z")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if want := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC); !s.Start.Equal(want) {
+		t.Errorf("expected %s, got %s", want, s.Start)
+	}
+	if want := time.Date(2024, 3, 1, 14, 30, 0, 0, time.UTC); s.Local == nil || !s.Local.Equal(want) {
+		t.Errorf("expected %s, got %v", want, s.Local)
+	}
+	if len(s.Holidays) != 2 || s.Holidays[1].Day() != 26 {
+		t.Errorf("unexpected holidays %v", s.Holidays)
+	}
+	if want := time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC); !s.Default.Equal(want) {
+		t.Errorf("expected %s, got %s", want, s.Default)
+	}
+
+	os.Setenv("START", "01/03/2024")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok || v.FieldName != "Start" {
+		t.Fatalf("expected ParseError for Start, got %v", err)
+	}
+	if _, ok := v.Err.(*time.ParseError); !ok {
+		t.Errorf("expected *time.ParseError, got %T", v.Err)
+	}
+
+	var bad struct {
+		Port int `layout:"2006"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for layout on a non-time field")
+	}
+	var badZone struct {
+		Start time.Time `location:"Mars/Olympus"`
+	}
+	if err := Process("env_config", &badZone); err == nil {
+		t.Error("expected error for an unknown location")
+	}
+}
+
+func TestDirective(t *testing.T) {
+	var s struct {
+		Directive  `envconfig:"prefix=directive,split_words=true"`
+		ListenPort int
+	}
+	os.Clearenv()
+	os.Setenv("DIRECTIVE_LISTEN_PORT", "8080")
+	os.Setenv("OVERRIDE_LISTENPORT", "9090")
+
+	if err := Process("", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.ListenPort != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.ListenPort)
+	}
+
+	// an explicit prefix takes precedence over the whole directive
+	if err := Process("override", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.ListenPort != 9090 {
+		t.Errorf("expected %d, got %d", 9090, s.ListenPort)
+	}
+}
+
+func TestDirectiveUnknownSetting(t *testing.T) {
+	var s struct {
+		Directive `envconfig:"prefix=directive,bogus=true"`
+	}
+	os.Clearenv()
+
+	if err := Process("", &s); err == nil {
+		t.Error("expected error for unknown directive setting")
+	}
+}
+
+func TestSortedSlices(t *testing.T) {
+	var s struct {
+		Names   []string `sort:"asc"`
+		Weights []int    `sort:"desc"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_NAMES", "carol,alice,bob")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "5,20,10")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if got := strings.Join(s.Names, ","); got != "alice,bob,carol" {
+		t.Errorf("expected %q, got %q", "alice,bob,carol", got)
+	}
+	if len(s.Weights) != 3 || s.Weights[0] != 20 || s.Weights[1] != 10 || s.Weights[2] != 5 {
+		t.Errorf("expected %v, got %v", []int{20, 10, 5}, s.Weights)
+	}
+}
+
+func TestSortUnorderedSlice(t *testing.T) {
+	var s struct {
+		Flags []bool `sort:"asc"`
+	}
+	os.Clearenv()
+
+	if err := Process("env_config", &s); err == nil {
+		t.Error("expected error for sort tag on unordered slice")
+	}
+}
+
+func TestDedupSlices(t *testing.T) {
+	var s struct {
+		Allow []string `sort:"asc" dedup:"true"`
+		Ports []int    `dedup:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ALLOW", "carol,alice,carol,bob,alice")
+	os.Setenv("ENV_CONFIG_PORTS", "80,443,80")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := strings.Join(s.Allow, ","); got != "alice,bob,carol" {
+		t.Errorf("expected %q, got %q", "alice,bob,carol", got)
+	}
+	if !reflect.DeepEqual(s.Ports, []int{80, 443}) {
+		t.Errorf("expected %v, got %v", []int{80, 443}, s.Ports)
+	}
+
+	var bad struct {
+		Flags []bool `dedup:"true"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for dedup tag on unordered slice")
+	}
+}
+
+func TestLastWins(t *testing.T) {
+	var s struct {
+		Host string `envconfig:"SERVICE_HOST" default:"localhost"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SERVICE_HOST", "prefixed")
+	os.Setenv("SERVICE_HOST", "alternate")
+
+	if err := ProcessX(&s, Options{Prefix: "env_config"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "prefixed" {
+		t.Errorf("expected %q, got %q", "prefixed", s.Host)
+	}
+
+	if err := ProcessX(&s, Options{Prefix: "env_config", LastWins: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "alternate" {
+		t.Errorf("expected %q, got %q", "alternate", s.Host)
+	}
+
+	os.Clearenv()
+	if err := ProcessX(&s, Options{Prefix: "env_config", LastWins: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "localhost" {
+		t.Errorf("expected %q, got %q", "localhost", s.Host)
+	}
+}
+
+func TestValidateSign(t *testing.T) {
+	type spec struct {
+		Timeout   time.Duration   `validate:"positive"`
+		Retries   int             `validate:"nonnegative"`
+		Intervals []time.Duration `validate:"positive"`
+	}
+
+	var s spec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s")
+	os.Setenv("ENV_CONFIG_RETRIES", "0")
+	os.Setenv("ENV_CONFIG_INTERVALS", "1s,2s")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_TIMEOUT", "-5s")
+	err := Process("env_config", &s)
+	v, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %T %v", err, err)
+	}
+	if v.FieldName != "Timeout" || v.Rule != "positive" {
+		t.Errorf("expected Timeout to fail rule positive, got %s and %s", v.FieldName, v.Rule)
+	}
+
+	os.Setenv("ENV_CONFIG_TIMEOUT", "5s")
+	os.Setenv("ENV_CONFIG_INTERVALS", "1s,-2s")
+	err = Process("env_config", &s)
+	v, ok = err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %T %v", err, err)
+	}
+	if v.FieldName != "Intervals" || !strings.HasPrefix(v.Err.Error(), "element 1: ") {
+		t.Errorf("expected element 1 of Intervals to fail, got %v", v)
+	}
+}
+
+func TestValidateUnknownRule(t *testing.T) {
+	var s struct {
+		Name string `validate:"positive"`
+	}
+	os.Clearenv()
+
+	if err := Process("env_config", &s); err == nil {
+		t.Error("expected error for numeric rule on string field")
+	}
+}
+
+type setterMethodSpec struct {
+	Endpoint string `setter:"ParseEndpoint"`
+}
+
+func (s *setterMethodSpec) ParseEndpoint(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("missing port in %q", value)
+	}
+	s.Endpoint = "tcp://" + value
+	return nil
+}
+
+func (s *setterMethodSpec) WrongSignature(value string) {}
+
+func TestSetterMethod(t *testing.T) {
+	var s setterMethodSpec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ENDPOINT", "localhost:8080")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "tcp://localhost:8080"; s.Endpoint != want {
+		t.Errorf("expected %q, got %q", want, s.Endpoint)
+	}
+
+	os.Setenv("ENV_CONFIG_ENDPOINT", "localhost")
+	if _, ok := Process("env_config", &s).(*ParseError); !ok {
+		t.Error("expected ParseError from setter method")
+	}
+}
+
+func TestSetterMethodInvalid(t *testing.T) {
+	var missing struct {
+		Endpoint string `setter:"Missing"`
+	}
+	var wrong struct {
+		setterMethodSpec
+		Other string `setter:"WrongSignature"`
+	}
+	os.Clearenv()
+
+	if err := Process("env_config", &missing); err == nil {
+		t.Error("expected error for missing setter method")
+	}
+	if err := Process("env_config", &wrong); err == nil {
+		t.Error("expected error for setter method with wrong signature")
+	}
+}
+
+func TestNegate(t *testing.T) {
+	type spec struct {
+		Feature bool `default:"true" negate:"no_feature"`
+	}
+
+	tests := []struct {
+		env  map[string]string
+		want bool
+	}{
+		{map[string]string{}, true},
+		{map[string]string{"ENV_CONFIG_FEATURE": "false"}, false},
+		{map[string]string{"NO_FEATURE": "true"}, false},
+		{map[string]string{"NO_FEATURE": "false"}, true},
+		{map[string]string{"ENV_CONFIG_FEATURE": "true", "NO_FEATURE": "true"}, false},
+	}
+	for _, test := range tests {
+		var s spec
+		os.Clearenv()
+		for k, v := range test.env {
+			os.Setenv(k, v)
+		}
+		if err := Process("env_config", &s); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Feature != test.want {
+			t.Errorf("%v: expected %v, got %v", test.env, test.want, s.Feature)
+		}
+	}
+
+	var s spec
+	os.Clearenv()
+	os.Setenv("NO_FEATURE", "maybe")
+	v, ok := Process("env_config", &s).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError for invalid negate value")
+	}
+	if v.KeyName != "NO_FEATURE" {
+		t.Errorf("expected %s, got %s", "NO_FEATURE", v.KeyName)
+	}
+}
+
+func TestNonEmptyItems(t *testing.T) {
+	var s struct {
+		Strict  []string `nonemptyitems:"true"`
+		Lenient []string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_STRICT", "a,b")
+	os.Setenv("ENV_CONFIG_LENIENT", "a,,b")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Lenient) != 3 {
+		t.Errorf("expected empty item to be kept, got %#v", s.Lenient)
+	}
+
+	os.Setenv("ENV_CONFIG_STRICT", "a, ,b")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %T %v", err, err)
+	}
+	if v.FieldName != "Strict" {
+		t.Errorf("expected %s, got %s", "Strict", v.FieldName)
+	}
+	if want := "element 1: empty item"; v.Err.Error() != want {
+		t.Errorf("expected %q, got %q", want, v.Err)
+	}
+}
+
+func TestNilEmptyStructs(t *testing.T) {
+	type TLSConfig struct {
+		Cert    string
+		Key     string
+		Verify  bool `default:"true"`
+		Options *struct {
+			MinVersion string
+		}
+	}
+	type spec struct {
+		TLS     *TLSConfig
+		Listen  string
+		Default *TLSConfig
+	}
+
+	var s spec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LISTEN", ":443")
+	if err := ProcessX(&s, Options{Prefix: "env_config", NilEmptyStructs: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.TLS != nil {
+		t.Errorf("expected nil TLS config, got %+v", s.TLS)
+	}
+
+	s = spec{}
+	os.Setenv("ENV_CONFIG_TLS_CERT", "cert.pem")
+	if err := ProcessX(&s, Options{Prefix: "env_config", NilEmptyStructs: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.TLS == nil {
+		t.Fatal("expected TLS config to be allocated")
+	}
+	if s.TLS.Cert != "cert.pem" || !s.TLS.Verify {
+		t.Errorf("expected cert and default verify, got %+v", s.TLS)
+	}
+	if s.TLS.Options != nil {
+		t.Errorf("expected nested options to stay nil, got %+v", s.TLS.Options)
+	}
+	if s.Default != nil {
+		t.Errorf("expected unrelated struct to stay nil, got %+v", s.Default)
+	}
+
+	// without the option every pointer is allocated, as before
+	s = spec{}
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Default == nil {
+		t.Error("expected struct to be allocated without NilEmptyStructs")
+	}
+}
+
+func TestWarnCaseVariants(t *testing.T) {
+	var s struct {
+		Host string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "upper")
+	os.Setenv("env_config_host", "lower")
+	os.Setenv("OTHER_KEY", "upper")
+	os.Setenv("other_key", "lower")
+
+	var reported [][]string
+	options := Options{
+		Prefix:           "env_config",
+		WarnCaseVariants: true,
+		CaseVariantFunc:  func(keys []string) { reported = append(reported, keys) },
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(reported) != 1 || strings.Join(reported[0], ",") != "ENV_CONFIG_HOST,env_config_host" {
+		t.Errorf("expected a single prefixed group, got %v", reported)
+	}
+	if s.Host != "upper" {
+		t.Errorf("expected %q, got %q", "upper", s.Host)
+	}
+}
+
+func TestLookupFunc(t *testing.T) {
+	var s struct {
+		Host    string `envconfig:"SERVICE_HOST"`
+		Port    int    `default:"80"`
+		Feature bool   `default:"true" negate:"NO_FEATURE"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+
+	env := map[string]string{
+		"SERVICE_HOST": "example.com",
+		"NO_FEATURE":   "true",
+	}
+	var looked []string
+	options := Options{
+		Prefix: "env_config",
+		LookupFunc: func(key string) (string, bool) {
+			looked = append(looked, key)
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Host != "example.com" {
+		t.Errorf("expected %q, got %q", "example.com", s.Host)
+	}
+	if s.Port != 80 {
+		t.Errorf("expected the environment to be ignored, got %d", s.Port)
+	}
+	if s.Feature {
+		t.Error("expected negate key to be read through LookupFunc")
+	}
+	if len(looked) == 0 {
+		t.Error("expected LookupFunc to be called")
+	}
+
+	os.Setenv("env_config_port", "8081")
+	options.WarnCaseVariants = true
+	options.CaseVariantFunc = func(keys []string) {
+		t.Errorf("expected the environment not to be scanned, got %v", keys)
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+}
+
+func TestValidateEnumSlice(t *testing.T) {
+	var s struct {
+		Features []string `enum:"search,billing,export"`
+		Mode     string   `enum:"fast,safe"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_FEATURES", "search,export")
+	os.Setenv("ENV_CONFIG_MODE", "safe")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_FEATURES", "search,chat")
+	err := Process("env_config", &s)
+	v, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %T %v", err, err)
+	}
+	if v.FieldName != "Features" || v.Rule != "enum" {
+		t.Errorf("expected Features to fail rule enum, got %s and %s", v.FieldName, v.Rule)
+	}
+	if want := `element 1: "chat" is not one of search, billing, export`; v.Err.Error() != want {
+		t.Errorf("expected %q, got %q", want, v.Err)
+	}
+}
+
+func TestValidateMapValues(t *testing.T) {
+	var s struct {
+		Timeouts map[string]time.Duration `validate:"min=1ms,max=30s"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TIMEOUTS", "users:250ms,reports:30s")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Timeouts["users"] != 250*time.Millisecond || s.Timeouts["reports"] != 30*time.Second {
+		t.Errorf("unexpected timeouts %v", s.Timeouts)
+	}
+
+	os.Setenv("ENV_CONFIG_TIMEOUTS", "users:250ms,reports:1m")
+	err := Process("env_config", &s)
+	v, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %T %v", err, err)
+	}
+	if v.FieldName != "Timeouts" || v.Rule != "max=30s" {
+		t.Errorf("expected Timeouts to fail rule max=30s, got %s and %s", v.FieldName, v.Rule)
+	}
+	if want := "key reports: value 1m0s is greater than 30s"; v.Err.Error() != want {
+		t.Errorf("expected %q, got %q", want, v.Err)
+	}
+}
+
+func TestValidateMalformedBound(t *testing.T) {
+	var s struct {
+		Timeout time.Duration `validate:"max=soon"`
+	}
+	os.Clearenv()
+
+	if err := Process("env_config", &s); err == nil {
+		t.Error("expected error for malformed bound")
+	}
+}
+
+func TestDeprecatedRemoved(t *testing.T) {
+	var s struct {
+		DatabaseURL string `deprecated_removed:"DB_HOST,db_url"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DATABASEURL", "postgres://db")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("DB_URL", "postgres://old")
+	err := Process("env_config", &s)
+	if err == nil {
+		t.Fatal("expected error for removed key")
+	}
+	if want := "environment variable DB_URL is no longer supported, use ENV_CONFIG_DATABASEURL instead"; err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err)
+	}
+}
+
+func TestCaseInsensitiveValues(t *testing.T) {
+	var s struct {
+		Debug bool
+		Level string   `enum:"debug,Info"`
+		Modes []string `enum:"fast,safe"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "tRuE")
+	os.Setenv("ENV_CONFIG_LEVEL", "INFO")
+	os.Setenv("ENV_CONFIG_MODES", "Fast,SAFE")
+
+	if err := Process("env_config", &s); err == nil {
+		t.Error("expected values to be case-sensitive by default")
+	}
+
+	if err := ProcessX(&s, Options{Prefix: "env_config", CaseInsensitiveValues: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Debug {
+		t.Errorf("expected %v, got %v", true, s.Debug)
+	}
+	if s.Level != "Info" {
+		t.Errorf("expected %q, got %q", "Info", s.Level)
+	}
+	if got := strings.Join(s.Modes, ","); got != "fast,safe" {
+		t.Errorf("expected %q, got %q", "fast,safe", got)
+	}
+}
+
+func TestAutoJSON(t *testing.T) {
+	var s struct {
+		Users  []string
+		Limits map[string]int
+		Plain  []int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_USERS", `["a,b", "c"]`)
+	os.Setenv("ENV_CONFIG_LIMITS", `{"read": 10, "write": 2}`)
+	os.Setenv("ENV_CONFIG_PLAIN", "1,2,3")
+
+	if err := ProcessX(&s, Options{Prefix: "env_config", AutoJSON: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Users) != 2 || s.Users[0] != "a,b" || s.Users[1] != "c" {
+		t.Errorf("expected %#v, got %#v", []string{"a,b", "c"}, s.Users)
+	}
+	if len(s.Limits) != 2 || s.Limits["read"] != 10 || s.Limits["write"] != 2 {
+		t.Errorf("unexpected limits %v", s.Limits)
+	}
+	if len(s.Plain) != 3 {
+		t.Errorf("expected comma-separated fallback, got %v", s.Plain)
+	}
+
+	os.Setenv("ENV_CONFIG_USERS", `["a",`)
+	v, ok := ProcessX(&s, Options{Prefix: "env_config", AutoJSON: true}).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError for malformed JSON")
+	}
+	if v.FieldName != "Users" {
+		t.Errorf("expected %s, got %s", "Users", v.FieldName)
+	}
+}
+
+func TestCSVRecords(t *testing.T) {
+	var s struct {
+		Routes [][]string `format:"csv" csvheader:"skip" csvcomment:"#"`
+		Plain  [][]string `format:"csv"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ROUTES", "path,backend\n# internal only\n/api,\"api:8080\"\n/,web")
+	os.Setenv("ENV_CONFIG_PLAIN", "a,b\nc,d")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(s.Routes) != 2 || s.Routes[0][1] != "api:8080" || s.Routes[1][0] != "/" {
+		t.Errorf("unexpected routes %q", s.Routes)
+	}
+	if len(s.Plain) != 2 || s.Plain[0][0] != "a" {
+		t.Errorf("unexpected records %q", s.Plain)
+	}
+
+	os.Setenv("ENV_CONFIG_PLAIN", "a,\"b")
+	v, ok := Process("env_config", &s).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError for malformed CSV")
+	}
+	if v.FieldName != "Plain" {
+		t.Errorf("expected %s, got %s", "Plain", v.FieldName)
+	}
+}
+
+func TestKeyMapper(t *testing.T) {
+	var s struct {
+		DB struct {
+			Host string
+		}
+		Broker string `envconfig:"BROKER"`
+	}
+	os.Clearenv()
+	os.Setenv("APP__DB__HOST", "db.internal")
+	os.Setenv("BROKER", "mq.internal")
+
+	options := Options{
+		Prefix:    "app",
+		KeyMapper: func(key string) string { return strings.Replace(key, "_", "__", -1) },
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.DB.Host != "db.internal" {
+		t.Errorf("expected %q, got %q", "db.internal", s.DB.Host)
+	}
+	if s.Broker != "mq.internal" {
+		t.Errorf("expected alternate key to be read unmapped, got %q", s.Broker)
+	}
+
+	var f struct {
+		Ports  []int `indexed:"true"`
+		Secret string
+	}
+	secret, err := ioutil.TempFile("", "envconfig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(secret.Name())
+	fmt.Fprint(secret, "hunter2\n")
+	secret.Close()
+	os.Unsetenv("APP__DB__HOST")
+	os.Setenv("APP__PORTS_0", "80")
+	os.Setenv("APP__PORTS_1", "443")
+	os.Setenv("APP__SECRET_FILE", secret.Name())
+	options.FileFallback = true
+	options.Strict = true
+	if err := ProcessX(&f, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(f.Ports, []int{80, 443}) {
+		t.Errorf("expected %v, got %v", []int{80, 443}, f.Ports)
+	}
+	if f.Secret != "hunter2" {
+		t.Errorf("expected %q, got %q", "hunter2", f.Secret)
+	}
+
+	vars, err := Gather("app", &s, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if vars[0].Key != "APP__DB__HOST" {
+		t.Errorf("expected %s, got %s", "APP__DB__HOST", vars[0].Key)
+	}
+
+	os.Clearenv()
+	env := map[string]string{"APP__DB__HOST": "exported"}
+	options.LookupFunc = func(key string) (string, bool) { v, ok := env[key]; return v, ok }
+	if err := ExportToEnvX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got := os.Getenv("APP__DB__HOST"); got != "exported" {
+		t.Errorf("expected export to use the mapped key, got %q", got)
+	}
+}
+
+func TestKeyMapperErrors(t *testing.T) {
+	var s struct {
+		Port  int
+		Host  string `required:"true"`
+		Level string `enum:"debug,info"`
+	}
+	options := Options{
+		Prefix:    "app",
+		KeyMapper: func(key string) string { return "X_" + key },
+	}
+
+	os.Clearenv()
+	os.Setenv("X_APP_PORT", "http")
+	os.Setenv("X_APP_HOST", "localhost")
+	v, ok := ProcessX(&s, options).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError")
+	}
+	if v.KeyName != "X_APP_PORT" {
+		t.Errorf("expected %s, got %s", "X_APP_PORT", v.KeyName)
+	}
+
+	os.Clearenv()
+	err := ProcessX(&s, options)
+	if err == nil || !strings.Contains(err.Error(), "required key X_APP_HOST missing value") {
+		t.Errorf("expected missing X_APP_HOST, got %v", err)
+	}
+
+	os.Setenv("X_APP_HOST", "localhost")
+	os.Setenv("X_APP_LEVEL", "trace")
+	verr, ok := ProcessX(&s, options).(*ValidationError)
+	if !ok {
+		t.Fatal("expected ValidationError")
+	}
+	if verr.KeyName != "X_APP_LEVEL" {
+		t.Errorf("expected %s, got %s", "X_APP_LEVEL", verr.KeyName)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	var s struct {
+		Throughput int    `units:"k=1000,m=1000000"`
+		Tokens     uint32 `units:"k=1000,m=1000000"`
+		Small      int8   `units:"k=1000"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_THROUGHPUT", "5k")
+	os.Setenv("ENV_CONFIG_TOKENS", "42")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Throughput != 5000 {
+		t.Errorf("expected %d, got %d", 5000, s.Throughput)
+	}
+	if s.Tokens != 42 {
+		t.Errorf("expected %d, got %d", 42, s.Tokens)
+	}
+
+	os.Setenv("ENV_CONFIG_TOKENS", "2g")
+	v, ok := Process("env_config", &s).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError for unknown unit")
+	}
+	if v.FieldName != "Tokens" || v.Err.Error() != `unknown unit "g"` {
+		t.Errorf("expected Tokens to fail with unknown unit, got %v", v)
+	}
+
+	os.Setenv("ENV_CONFIG_TOKENS", "1")
+	os.Setenv("ENV_CONFIG_SMALL", "1k")
+	if _, ok := Process("env_config", &s).(*ParseError); !ok {
+		t.Error("expected ParseError for overflow")
+	}
+}
+
+func TestIntegerBases(t *testing.T) {
+	var s struct {
+		Flags uint8
+		Mode  uint32
+		Mask  int
+		Bits  []int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_FLAGS", "0xFF")
+	os.Setenv("ENV_CONFIG_MODE", "0o755")
+	os.Setenv("ENV_CONFIG_MASK", "-0b101")
+	os.Setenv("ENV_CONFIG_BITS", "0x10,010,0b11,7")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Flags != 0xFF || s.Mode != 0755 || s.Mask != -5 {
+		t.Errorf("unexpected values %+v", s)
+	}
+	if !reflect.DeepEqual(s.Bits, []int{16, 8, 3, 7}) {
+		t.Errorf("expected %v, got %v", []int{16, 8, 3, 7}, s.Bits)
+	}
+
+	os.Setenv("ENV_CONFIG_FLAGS", "0x100")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Flags" {
+		t.Errorf("expected ParseError for Flags, got %v", v)
+	}
+
+	os.Setenv("ENV_CONFIG_FLAGS", "0xFF")
+	os.Setenv("ENV_CONFIG_BITS", "0x10,0xZZ")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Bits" {
+		t.Errorf("expected ParseError for Bits, got %v", v)
+	}
+}
+
+func TestByteFields(t *testing.T) {
+	var s struct {
+		Raw    []byte
+		Key    []byte  `encoding:"base64"`
+		Digest [4]byte `encoding:"hex"`
+		Salt   *[]byte `encoding:"base64url"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_RAW", "a,b")
+	os.Setenv("ENV_CONFIG_KEY", "c2VjcmV0")
+	os.Setenv("ENV_CONFIG_DIGEST", "deadbeef")
+	os.Setenv("ENV_CONFIG_SALT", "-_8=")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if string(s.Raw) != "a,b" {
+		t.Errorf("expected %q, got %q", "a,b", s.Raw)
+	}
+	if string(s.Key) != "secret" {
+		t.Errorf("expected %q, got %q", "secret", s.Key)
+	}
+	if s.Digest != [4]byte{0xde, 0xad, 0xbe, 0xef} {
+		t.Errorf("expected %x, got %x", "deadbeef", s.Digest)
+	}
+	if s.Salt == nil || !bytes.Equal(*s.Salt, []byte{0xfb, 0xff}) {
+		t.Errorf("expected %v, got %v", []byte{0xfb, 0xff}, s.Salt)
+	}
+
+	env, err := Marshal("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if env[2].Value != "deadbeef" || env[1].Value != "c2VjcmV0" {
+		t.Errorf("expected bytes to be encoded, got %v", env)
+	}
+
+	os.Setenv("ENV_CONFIG_KEY", "zz!")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Key" {
+		t.Errorf("expected ParseError for Key, got %v", v)
+	}
+	os.Setenv("ENV_CONFIG_KEY", "c2VjcmV0")
+	os.Setenv("ENV_CONFIG_DIGEST", "zz")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Digest" {
+		t.Errorf("expected ParseError for Digest, got %v", v)
+	}
+	os.Setenv("ENV_CONFIG_DIGEST", "dead")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Digest" {
+		t.Errorf("expected ParseError for a short array, got %v", v)
+	}
+}
+
+func TestBoolWords(t *testing.T) {
+	var s struct {
+		Feature bool
+		Cache   bool
+		Debug   bool
+		Flags   map[string]bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_FEATURE", "Yes")
+	os.Setenv("ENV_CONFIG_CACHE", "ENABLED")
+	os.Setenv("ENV_CONFIG_DEBUG", "on")
+	os.Setenv("ENV_CONFIG_FLAGS", "a:off,b:yes")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Feature || !s.Cache || !s.Debug {
+		t.Errorf("expected all true, got %+v", s)
+	}
+	if s.Flags["a"] || !s.Flags["b"] {
+		t.Errorf("unexpected flags %v", s.Flags)
+	}
+
+	os.Setenv("ENV_CONFIG_FEATURE", "off")
+	os.Setenv("ENV_CONFIG_CACHE", "0")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Feature || s.Cache {
+		t.Errorf("expected false, got %+v", s)
+	}
+
+	os.Setenv("ENV_CONFIG_DEBUG", "maybe")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Debug" {
+		t.Errorf("expected ParseError for Debug, got %v", v)
+	}
+}
+
+func TestByteSize(t *testing.T) {
+	var s struct {
+		MaxUpload int64   `bytesize:"true"`
+		Buffer    uint32  `bytesize:"true"`
+		Cache     *uint64 `bytesize:"true"`
+		Plain     int     `bytesize:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_MAXUPLOAD", "256MB")
+	os.Setenv("ENV_CONFIG_BUFFER", "64kib")
+	os.Setenv("ENV_CONFIG_CACHE", "2 GiB")
+	os.Setenv("ENV_CONFIG_PLAIN", "512")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.MaxUpload != 256000000 {
+		t.Errorf("expected %d, got %d", 256000000, s.MaxUpload)
+	}
+	if s.Buffer != 65536 {
+		t.Errorf("expected %d, got %d", 65536, s.Buffer)
+	}
+	if s.Cache == nil || *s.Cache != 2<<30 {
+		t.Errorf("expected %d, got %v", 2<<30, s.Cache)
+	}
+	if s.Plain != 512 {
+		t.Errorf("expected %d, got %d", 512, s.Plain)
+	}
+
+	os.Setenv("ENV_CONFIG_BUFFER", "5PB")
+	v, ok := Process("env_config", &s).(*ParseError)
+	if !ok || v.FieldName != "Buffer" || v.Err.Error() != `unknown unit "pb"` {
+		t.Errorf("expected Buffer to fail with unknown unit, got %v", v)
+	}
+}
+
+func TestValidateMultipleOf(t *testing.T) {
+	var s struct {
+		BufferSize uint `validate:"multipleof=4096"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BUFFERSIZE", "8192")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_BUFFERSIZE", "5000")
+	err := Process("env_config", &s)
+	v, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("expected ValidationError, got %T %v", err, err)
+	}
+	if want := "value 5000 is not a multiple of 4096"; v.FieldName != "BufferSize" || v.Err.Error() != want {
+		t.Errorf("expected BufferSize to fail with %q, got %v", want, v)
+	}
+
+	var bad struct {
+		Size int `validate:"multipleof=0"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for zero multiple")
+	}
+}
+
+type blockingDecoder struct{}
+
+func (blockingDecoder) Decode(value string) error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestProcessTimeout(t *testing.T) {
+	var fast struct {
+		Port int
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	if err := ProcessTimeout("env_config", &fast, time.Second); err != nil {
+		t.Fatal(err.Error())
+	}
+	if fast.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, fast.Port)
+	}
+
+	var slow struct {
+		Backend blockingDecoder
+	}
+	os.Setenv("ENV_CONFIG_BACKEND", "remote")
+	if err := ProcessTimeout("env_config", &slow, 10*time.Millisecond); err != ErrTimeout {
+		t.Errorf("expected %v, got %v", ErrTimeout, err)
+	}
+}
+
+func TestAutoSeparator(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"a,b", []string{"a", "b"}},
+		{"a;b,c", []string{"a", "b,c"}},
+		{"a;b\r\nc,d", []string{"a;b", "c,d"}},
+	}
+	for _, test := range tests {
+		var s struct {
+			Paths []string
+		}
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_PATHS", test.value)
+		if err := ProcessX(&s, Options{Prefix: "env_config", AutoSeparator: true}); err != nil {
+			t.Fatal(err.Error())
+		}
+		if !reflect.DeepEqual(s.Paths, test.want) {
+			t.Errorf("%q: expected %#v, got %#v", test.value, test.want, s.Paths)
+		}
+	}
+}
+
+func TestIndexedSlices(t *testing.T) {
+	var s struct {
+		Items  []string `indexed:"true"`
+		Sparse []int    `indexed:"sparse"`
+		Plain  []string `indexed:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_ITEMS_0", "a,b")
+	os.Setenv("ENV_CONFIG_ITEMS_1", "c")
+	os.Setenv("ENV_CONFIG_ITEMS_3", "ignored after gap")
+	os.Setenv("ENV_CONFIG_SPARSE_0", "1")
+	os.Setenv("ENV_CONFIG_SPARSE_2", "3")
+	os.Setenv("ENV_CONFIG_PLAIN", "x,y")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(s.Items, []string{"a,b", "c"}) {
+		t.Errorf("expected %#v, got %#v", []string{"a,b", "c"}, s.Items)
+	}
+	if !reflect.DeepEqual(s.Sparse, []int{1, 0, 3}) {
+		t.Errorf("expected %#v, got %#v", []int{1, 0, 3}, s.Sparse)
+	}
+	if !reflect.DeepEqual(s.Plain, []string{"x", "y"}) {
+		t.Errorf("expected fallback to the plain key, got %#v", s.Plain)
+	}
+
+	os.Setenv("ENV_CONFIG_SPARSE_50", "50")
+	if err := ProcessX(&s, Options{Prefix: "env_config", MaxIndex: 10}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Sparse) != 3 {
+		t.Errorf("expected indexes past MaxIndex to be ignored, got %d elements", len(s.Sparse))
+	}
+
+	os.Setenv("ENV_CONFIG_SPARSE_1", "two")
+	v, ok := Process("env_config", &s).(*ParseError)
+	if !ok {
+		t.Fatal("expected ParseError for malformed element")
+	}
+	if v.KeyName != "ENV_CONFIG_SPARSE_1" {
+		t.Errorf("expected %s, got %s", "ENV_CONFIG_SPARSE_1", v.KeyName)
+	}
+
+	var checked struct {
+		Ports []int    `indexed:"true" min:"1"`
+		Modes []string `indexed:"true" oneof:"r w"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORTS_0", "80")
+	os.Setenv("ENV_CONFIG_PORTS_1", "0")
+	v, ok = Process("env_config", &checked).(*ParseError)
+	if !ok || v.KeyName != "ENV_CONFIG_PORTS_1" {
+		t.Errorf("expected ParseError for element below min, got %v", v)
+	}
+	os.Setenv("ENV_CONFIG_PORTS_1", "443")
+	os.Setenv("ENV_CONFIG_MODES_0", "x")
+	v, ok = Process("env_config", &checked).(*ParseError)
+	if !ok || v.KeyName != "ENV_CONFIG_MODES_0" {
+		t.Errorf("expected ParseError for element outside oneof, got %v", v)
+	}
+}
+
+func TestIndexedExpandVars(t *testing.T) {
+	var s struct {
+		Paths []string `indexed:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("BASE", "/srv")
+	os.Setenv("ENV_CONFIG_PATHS_0", "${BASE}/a")
+	os.Setenv("ENV_CONFIG_PATHS_1", "$BASE/b")
+	if err := ProcessX(&s, Options{Prefix: "env_config", ExpandVars: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	want := []string{"/srv/a", "/srv/b"}
+	if !reflect.DeepEqual(s.Paths, want) {
+		t.Errorf("expected %#v, got %#v", want, s.Paths)
+	}
+}
+
+func TestProcessWithJSONSeed(t *testing.T) {
+	type spec struct {
+		Port     int    `default:"80"`
+		Host     string `required:"true"`
+		LogLevel string `default:"info"`
+		DB       struct {
+			User string
+			Pool int `default:"4"`
+		}
+	}
+
+	var s spec
+	os.Clearenv()
+	os.Setenv("APP_CONFIG_JSON", `{"Port": 8080, "Host": "seed.local", "DB": {"User": "seed", "Pool": 10}}`)
+	os.Setenv("APP_DB_USER", "env")
+	if err := ProcessWithJSONSeed("app", "APP_CONFIG_JSON", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Port != 8080 || s.Host != "seed.local" || s.DB.Pool != 10 {
+		t.Errorf("expected seeded values to survive, got %+v", s)
+	}
+	if s.DB.User != "env" {
+		t.Errorf("expected environment to override seed, got %q", s.DB.User)
+	}
+	if s.LogLevel != "info" {
+		t.Errorf("expected default for unseeded field, got %q", s.LogLevel)
+	}
+
+	var flags struct {
+		Debug   bool `default:"true"`
+		Port    int  `default:"8080"`
+		Verbose bool `default:"true"`
+	}
+	os.Setenv("APP_CONFIG_JSON", `{"Debug": false, "port": 0, "Verbose": null}`)
+	if err := ProcessWithJSONSeed("app", "APP_CONFIG_JSON", &flags); err != nil {
+		t.Fatal(err.Error())
+	}
+	if flags.Debug || flags.Port != 0 {
+		t.Errorf("expected seeded zero values to survive, got %+v", flags)
+	}
+	if !flags.Verbose {
+		t.Errorf("expected default for null seed value, got %+v", flags)
+	}
+
+	os.Clearenv()
+	env := map[string]string{"APP_CONFIG_JSON": `{"Port": 9090}`}
+	options := Options{
+		Prefix:     "app",
+		LookupFunc: func(key string) (string, bool) { v, ok := env[key]; return v, ok },
+	}
+	if err := ProcessWithJSONSeedX("APP_CONFIG_JSON", &flags, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if flags.Port != 9090 {
+		t.Errorf("expected seed from LookupFunc, got %d", flags.Port)
+	}
+
+	os.Setenv("APP_CONFIG_JSON", `{"Port": `)
+	err := ProcessWithJSONSeed("app", "APP_CONFIG_JSON", &s)
+	if err == nil || !strings.Contains(err.Error(), "APP_CONFIG_JSON") {
+		t.Errorf("expected error naming the seed key, got %v", err)
+	}
+}
+
+func TestBoolMapSetStyle(t *testing.T) {
+	var s struct {
+		Features map[string]bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_FEATURES", "alpha,beta,gamma:false")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[string]bool{"alpha": true, "beta": true, "gamma": false}
+	if !reflect.DeepEqual(s.Features, expected) {
+		t.Errorf("expected %v, got %v", expected, s.Features)
+	}
+
+	os.Setenv("ENV_CONFIG_FEATURES", "alpha:true:false")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Features" {
+		t.Errorf("expected ParseError for Features, got %v", err)
+	}
+}
+
+func TestExportToEnv(t *testing.T) {
+	var s struct {
+		Port    int           `default:"8080"`
+		Debug   bool          `default:"true"`
+		Timeout time.Duration `default:"1m30s"`
+		Hosts   []string      `default:"a,b"`
+		Limits  map[string]int
+		Secret  Password
+		Unset   string
+		Nested  struct {
+			Level string `default:"info"`
+		}
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "false")
+	os.Setenv("ENV_CONFIG_LIMITS", "b:2,a:1")
+	os.Setenv("ENV_CONFIG_SECRET", "hunter2")
+	if err := ExportToEnv("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[string]string{
+		"ENV_CONFIG_PORT":         "8080",
+		"ENV_CONFIG_DEBUG":        "false",
+		"ENV_CONFIG_TIMEOUT":      "1m30s",
+		"ENV_CONFIG_HOSTS":        "a,b",
+		"ENV_CONFIG_LIMITS":       "a:1,b:2",
+		"ENV_CONFIG_SECRET":       "hunter2",
+		"ENV_CONFIG_NESTED_LEVEL": "info",
+	}
+	for key, want := range expected {
+		if got := os.Getenv(key); got != want {
+			t.Errorf("expected %s=%q, got %q", key, want, got)
+		}
+	}
+	if _, ok := os.LookupEnv("ENV_CONFIG_UNSET"); ok {
+		t.Error("expected ENV_CONFIG_UNSET to stay unset")
+	}
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SECRET", "hunter2")
+	if err := ExportToEnvX(&s, Options{Prefix: "other", OmitSecrets: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if _, ok := os.LookupEnv("OTHER_SECRET"); ok {
+		t.Error("expected OTHER_SECRET to be omitted")
+	}
+	if got := os.Getenv("OTHER_PORT"); got != "8080" {
+		t.Errorf("expected %q, got %q", "8080", got)
+	}
+
+	var z struct {
+		Count   int
+		Enabled bool
+		Unset   int
+	}
+	os.Clearenv()
+	lookup := map[string]string{"ENV_CONFIG_COUNT": "0", "ENV_CONFIG_ENABLED": "false"}
+	options := Options{
+		Prefix:     "env_config",
+		LookupFunc: func(key string) (string, bool) { v, ok := lookup[key]; return v, ok },
+	}
+	if err := ExportToEnvX(&z, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if got, ok := os.LookupEnv("ENV_CONFIG_COUNT"); !ok || got != "0" {
+		t.Errorf("expected ENV_CONFIG_COUNT=%q, got %q", "0", got)
+	}
+	if got, ok := os.LookupEnv("ENV_CONFIG_ENABLED"); !ok || got != "false" {
+		t.Errorf("expected ENV_CONFIG_ENABLED=%q, got %q", "false", got)
+	}
+	if _, ok := os.LookupEnv("ENV_CONFIG_UNSET"); ok {
+		t.Error("expected ENV_CONFIG_UNSET to stay unset")
+	}
+
+	var n struct {
+		Port int `default:"80"`
+		TLS  *struct {
+			Verify bool `default:"true"`
+		}
+	}
+	os.Clearenv()
+	if err := ExportToEnvX(&n, Options{Prefix: "env_config", NilEmptyStructs: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if n.TLS != nil {
+		t.Errorf("expected empty struct pointer to stay nil, got %+v", n.TLS)
+	}
+	if _, ok := os.LookupEnv("ENV_CONFIG_TLS_VERIFY"); ok {
+		t.Error("expected nothing beneath a nil struct pointer to be exported")
+	}
+	if got := os.Getenv("ENV_CONFIG_PORT"); got != "80" {
+		t.Errorf("expected %q, got %q", "80", got)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	var s struct {
+		Tags   []string `pipe:"trim,lower"`
+		Name   string   `pipe:"unquote,upper"`
+		Quoted []string `pipe:"trim,unquote"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TAGS", "A , B")
+	os.Setenv("ENV_CONFIG_NAME", `"kelsey"`)
+	os.Setenv("ENV_CONFIG_QUOTED", `"a", b`)
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !reflect.DeepEqual(s.Tags, []string{"a", "b"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b"}, s.Tags)
+	}
+	if s.Name != "KELSEY" {
+		t.Errorf("expected %q, got %q", "KELSEY", s.Name)
+	}
+	if !reflect.DeepEqual(s.Quoted, []string{"a", "b"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b"}, s.Quoted)
+	}
+
+	os.Setenv("ENV_CONFIG_QUOTED", `"a","b`)
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Quoted" || !strings.Contains(err.Error(), "element 1") {
+		t.Errorf("expected ParseError for element 1 of Quoted, got %v", err)
+	}
+
+	var bad struct {
+		Name string `pipe:"reverse"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for unknown pipe transform")
+	}
+}
+
+func TestMissingRequired(t *testing.T) {
+	var s struct {
+		Host     string `required:"true" desc:"server host"`
+		Port     int    `required:"true" default:"80"`
+		User     string `required:"true" envconfig:"SERVICE_USER"`
+		Optional string
+		DB       struct {
+			Password string `required:"true"`
+		}
+	}
+	os.Clearenv()
+	os.Setenv("SERVICE_USER", "kelsey")
+
+	missing, err := MissingRequired("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(missing) != 2 {
+		t.Fatalf("expected 2 missing variables, got %v", missing)
+	}
+	if missing[0].Key != "ENV_CONFIG_HOST" || missing[0].Description != "server host" || missing[0].Type.Kind() != reflect.String {
+		t.Errorf("unexpected info %+v", missing[0])
+	}
+	if missing[1].Key != "ENV_CONFIG_DB_PASSWORD" || !missing[1].Required {
+		t.Errorf("unexpected info %+v", missing[1])
+	}
+
+	var conditional struct {
+		Mode     string `default:"dev"`
+		Cert     string `required_if:"Mode=prod"`
+		Key      string `required_if:"Mode=prod" default:"key.pem"`
+		Upstream string `required_if:"ENV_CONFIG_MODE=dev"`
+	}
+	os.Clearenv()
+	missing, err = MissingRequired("env_config", &conditional)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(missing) != 1 || missing[0].Key != "ENV_CONFIG_UPSTREAM" {
+		t.Errorf("expected only ENV_CONFIG_UPSTREAM to be missing, got %+v", missing)
+	}
+	os.Setenv("ENV_CONFIG_MODE", "prod")
+	missing, err = MissingRequired("env_config", &conditional)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(missing) != 1 || missing[0].Key != "ENV_CONFIG_CERT" {
+		t.Errorf("expected only ENV_CONFIG_CERT to be missing, got %+v", missing)
+	}
+}
+
+func TestGather(t *testing.T) {
+	var s struct {
+		MaxConns int    `required:"true" default:"10" desc:"pool size"`
+		User     string `env:"DB_USER"`
+		Cache    struct {
+			TTL time.Duration
+		}
+	}
+	vars, err := Gather("env_config", &s, Options{SplitWords: true, TagName: "env"})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(vars) != 3 {
+		t.Fatalf("expected 3 variables, got %v", vars)
+	}
+	v := vars[0]
+	if v.Name != "MaxConns" || v.Key != "ENV_CONFIG_MAX_CONNS" || v.Default != "10" || !v.Required || v.Description != "pool size" {
+		t.Errorf("unexpected info %+v", v)
+	}
+	if v := vars[1]; v.Key != "ENV_CONFIG_DB_USER" || v.AltKey != "DB_USER" {
+		t.Errorf("unexpected info %+v", v)
+	}
+	if v := vars[2]; v.Key != "ENV_CONFIG_CACHE_TTL" || v.Type != reflect.TypeOf(time.Duration(0)) {
+		t.Errorf("unexpected info %+v", v)
+	}
+
+	if _, err := Gather("env_config", s, Options{}); err != ErrInvalidSpecification {
+		t.Errorf("expected %v, got %v", ErrInvalidSpecification, err)
+	}
+}
+
+func TestProcessChanged(t *testing.T) {
+	var s struct {
+		Port  int    `default:"80"`
+		Host  string `envconfig:"SERVICE_HOST"`
+		Level string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("SERVICE_HOST", "a.local")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	snap, err := Snapshot("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// a change made behind envconfig's back shows which fields are skipped
+	s.Port = 1
+	os.Setenv("SERVICE_HOST", "b.local")
+	os.Setenv("ENV_CONFIG_LEVEL", "debug")
+	changed, err := ProcessChanged("env_config", &s, snap)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := []string{"SERVICE_HOST", "ENV_CONFIG_LEVEL"}
+	if !reflect.DeepEqual(changed, expected) {
+		t.Errorf("expected %v, got %v", expected, changed)
+	}
+	if s.Host != "b.local" || s.Level != "debug" {
+		t.Errorf("expected changed fields to be updated, got %+v", s)
+	}
+	if s.Port != 1 {
+		t.Errorf("expected unchanged field to be left alone, got %d", s.Port)
+	}
+
+	os.Unsetenv("ENV_CONFIG_PORT")
+	snap["SERVICE_HOST"] = "b.local"
+	snap["ENV_CONFIG_LEVEL"] = "debug"
+	changed, err = ProcessChanged("env_config", &s, snap)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(changed, []string{"ENV_CONFIG_PORT"}) || s.Port != 80 {
+		t.Errorf("expected unset port to fall back to default, got %v and %d", changed, s.Port)
+	}
+
+	snap, err = Snapshot("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	os.Unsetenv("SERVICE_HOST")
+	changed, err = ProcessChanged("env_config", &s, snap)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(changed, []string{"SERVICE_HOST"}) || s.Host != "" {
+		t.Errorf("expected unset host to be cleared, got %v and %q", changed, s.Host)
+	}
+
+	os.Clearenv()
+	env := map[string]string{"APP_LEVEL": "info"}
+	options := Options{
+		Prefix:     "app",
+		LookupFunc: func(key string) (string, bool) { v, ok := env[key]; return v, ok },
+	}
+	snap, err = SnapshotX(&s, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	env["APP_LEVEL"] = "warn"
+	changed, err = ProcessChangedX(&s, snap, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(changed, []string{"APP_LEVEL"}) || s.Level != "warn" {
+		t.Errorf("expected LookupFunc to be used, got %v and %q", changed, s.Level)
+	}
+}
+
+func TestBlankSlice(t *testing.T) {
+	var s struct {
+		Names []string
+		Ports []int `nonemptyitems:"true"`
+	}
+	for _, value := range []string{"", "  ", ",", " , "} {
+		os.Clearenv()
+		os.Setenv("ENV_CONFIG_NAMES", value)
+		os.Setenv("ENV_CONFIG_PORTS", value)
+		s.Names, s.Ports = []string{"stale"}, []int{1}
+		if err := Process("env_config", &s); err != nil {
+			t.Fatalf("unexpected error for %q: %v", value, err)
+		}
+
+		if s.Names == nil || len(s.Names) != 0 {
+			t.Errorf("expected empty slice for %q, got %#v", value, s.Names)
+		}
+		if s.Ports == nil || len(s.Ports) != 0 {
+			t.Errorf("expected empty slice for %q, got %#v", value, s.Ports)
+		}
+	}
+}
+
+func TestDelimiter(t *testing.T) {
+	var s struct {
+		Paths  []string       `envconfig:"PATHS" delimiter:";"`
+		Window [2]int         `delimiter:"x"`
+		Limits map[string]int `delimiter:";"`
+		Hosts  [3]string
+	}
+	os.Clearenv()
+	os.Setenv("PATHS", `C:\a,b;C:\c`)
+	os.Setenv("ENV_CONFIG_WINDOW", "800x600")
+	os.Setenv("ENV_CONFIG_LIMITS", "a:1;b:2")
+	os.Setenv("ENV_CONFIG_HOSTS", "a,b,c")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if expected := []string{`C:\a,b`, `C:\c`}; !reflect.DeepEqual(s.Paths, expected) {
+		t.Errorf("expected %v, got %v", expected, s.Paths)
+	}
+	if s.Window != [2]int{800, 600} {
+		t.Errorf("expected %v, got %v", [2]int{800, 600}, s.Window)
+	}
+	if expected := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(s.Limits, expected) {
+		t.Errorf("expected %v, got %v", expected, s.Limits)
+	}
+	if s.Hosts != [3]string{"a", "b", "c"} {
+		t.Errorf("expected %v, got %v", [3]string{"a", "b", "c"}, s.Hosts)
+	}
+
+	os.Setenv("ENV_CONFIG_WINDOW", "800x600x32")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Window" {
+		t.Errorf("expected ParseError for Window, got %v", err)
+	}
+}
+
+func TestMapSeparator(t *testing.T) {
+	var s struct {
+		Upstreams map[string]string `envconfig:"HOSTS" separator:"="`
+		Weights   map[string]int    `delimiter:";" separator:"="`
+	}
+	os.Clearenv()
+	os.Setenv("HOSTS", "api=http://[::1]:8080,web=http://localhost:80")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a=1;b=2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	expected := map[string]string{"api": "http://[::1]:8080", "web": "http://localhost:80"}
+	if !reflect.DeepEqual(s.Upstreams, expected) {
+		t.Errorf("expected %v, got %v", expected, s.Upstreams)
+	}
+	if !reflect.DeepEqual(s.Weights, map[string]int{"a": 1, "b": 2}) {
+		t.Errorf("expected %v, got %v", map[string]int{"a": 1, "b": 2}, s.Weights)
+	}
+
+	os.Setenv("ENV_CONFIG_WEIGHTS", "a:1;b:2")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Weights" {
+		t.Errorf("expected ParseError for Weights, got %v", v)
+	}
+}
+
+func TestCheckSpec(t *testing.T) {
+	type good struct {
+		Port    int           `default:"8080" validate:"positive"`
+		Timeout time.Duration `default:"1s"`
+		Hosts   []string      `default:"a,b"`
+	}
+	if err := CheckSpec((*good)(nil)); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := CheckSpec(good{}); err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if err := CheckSpec(42); err != ErrInvalidSpecification {
+		t.Errorf("expected ErrInvalidSpecification, got %v", err)
+	}
+
+	type bad struct {
+		Port    int    `default:"http"`
+		Workers int    `default:"-1" validate:"positive"`
+		Host    string `envconfig:"ADDR"`
+		Addr    string
+		Timeout time.Duration `default:"1s"`
+	}
+	err := CheckSpec((*bad)(nil))
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"field Port: invalid default", "field Workers: invalid default", "fields Host and Addr both use key ADDR"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %q", want, err)
+		}
+	}
+
+	type badChecks struct {
+		Port  int    `default:"0" min:"1"`
+		Mode  string `default:"x" oneof:"r w"`
+		Name  string `default:"Bad Name" pattern:"^[a-z]+$"`
+		Label string `default:"too long" maxbytes:"3"`
+	}
+	err = CheckSpec((*badChecks)(nil))
+	for _, want := range []string{"field Port: invalid default", "field Mode: invalid default", "field Name: invalid default", "field Label: invalid default"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+
+	type badTag struct {
+		Names []string `sort:"random"`
+	}
+	if err := CheckSpec((*badTag)(nil)); err == nil {
+		t.Error("expected error for invalid sort tag")
+	}
+}
+
+func TestProcessAll(t *testing.T) {
+	var s struct {
+		Port    int `required:"true"`
+		Debug   bool
+		Timeout time.Duration `validate:"positive"`
+		Host    string        `required:"true"`
+		Name    string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEBUG", "maybe")
+	os.Setenv("ENV_CONFIG_TIMEOUT", "-1s")
+	os.Setenv("ENV_CONFIG_NAME", "kelsey")
+
+	if _, ok := Process("env_config", &s).(*ParseError); !ok {
+		t.Error("expected Process to stop at the first ParseError")
+	}
+
+	err := ProcessAll("env_config", &s)
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 4 {
+		t.Fatalf("expected 4 errors, got %v", err)
+	}
+	if lines := strings.Split(err.Error(), "\n"); len(lines) != 4 {
+		t.Errorf("expected one line per error, got %q", err)
+	}
+
+	var perr *ParseError
+	if !errors.As(err, &perr) || perr.FieldName != "Debug" {
+		t.Errorf("expected ParseError for Debug, got %v", perr)
+	}
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.FieldName != "Timeout" {
+		t.Errorf("expected ValidationError for Timeout, got %v", verr)
+	}
+	// As is called directly too, since errors.As only follows
+	// Unwrap() []error from Go 1.20 on
+	verr = nil
+	if !errs.As(&verr) || verr.FieldName != "Timeout" {
+		t.Errorf("expected Errors.As to find the ValidationError, got %v", verr)
+	}
+	if !errs.Is(errs[0]) || errs.Is(ErrInvalidSpecification) {
+		t.Error("expected Errors.Is to match only its own errors")
+	}
+	if s.Name != "kelsey" {
+		t.Errorf("expected fields after a failure to be set, got %q", s.Name)
+	}
+}
+
+func TestEmptyValue(t *testing.T) {
+	type spec struct {
+		Hosts  []string       `default:"a,b" emptyvalue:"NONE"`
+		Limits map[string]int `default:"a:1" emptyvalue:"-"`
+	}
+
+	var s spec
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(s.Hosts) != 2 || len(s.Limits) != 1 {
+		t.Errorf("expected defaults when unset, got %v and %v", s.Hosts, s.Limits)
+	}
+
+	s = spec{}
+	os.Setenv("ENV_CONFIG_HOSTS", "NONE")
+	os.Setenv("ENV_CONFIG_LIMITS", "-")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Hosts == nil || len(s.Hosts) != 0 {
+		t.Errorf("expected empty slice for sentinel, got %#v", s.Hosts)
+	}
+	if s.Limits == nil || len(s.Limits) != 0 {
+		t.Errorf("expected empty map for sentinel, got %#v", s.Limits)
+	}
+
+	s = spec{}
+	os.Setenv("ENV_CONFIG_HOSTS", "NONE,c")
+	os.Setenv("ENV_CONFIG_LIMITS", "b:2")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(s.Hosts, []string{"NONE", "c"}) || !reflect.DeepEqual(s.Limits, map[string]int{"b": 2}) {
+		t.Errorf("expected real values to be parsed, got %v and %v", s.Hosts, s.Limits)
+	}
+
+	var bad struct {
+		Name string `emptyvalue:"NONE"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for emptyvalue on a string")
+	}
+}
+
+func TestProcessFromFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "envconfig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, `# local settings
+ENV_CONFIG_PORT=8080
+
+export ENV_CONFIG_USER="kelsey hightower"
+ENV_CONFIG_GREETING='a=b # not a comment'
+`)
+	f.Close()
+
+	var s struct {
+		Port     int
+		User     string
+		Greeting string
+		Debug    bool
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "9090")
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	if err := ProcessFromFile("env_config", &s, f.Name()); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if s.Port != 8080 {
+		t.Errorf("expected the file to win, got %d", s.Port)
+	}
+	if s.User != "kelsey hightower" || s.Greeting != "a=b # not a comment" {
+		t.Errorf("expected quotes to be stripped, got %q and %q", s.User, s.Greeting)
+	}
+	if !s.Debug {
+		t.Error("expected fallback to the environment")
+	}
+
+	_, err = parseDotEnv(strings.NewReader("A=1\n\nnot an entry\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected error naming line 3, got %v", err)
+	}
+}
+
+func TestFileFallback(t *testing.T) {
+	f, err := ioutil.TempFile("", "envconfig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "s3cret\n")
+	f.Close()
+
+	var s struct {
+		Password string `required:"true"`
+		User     string `envconfig:"DB_USER"`
+		Host     string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PASSWORD_FILE", f.Name())
+	os.Setenv("DB_USER_FILE", f.Name())
+	os.Setenv("ENV_CONFIG_HOST", "db.local")
+	os.Setenv("ENV_CONFIG_HOST_FILE", "/nonexistent")
+
+	if err := Process("env_config", &s); err == nil {
+		t.Error("expected _FILE variables to be ignored by default")
+	}
+
+	options := Options{Prefix: "env_config", FileFallback: true}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Password != "s3cret" || s.User != "s3cret" {
+		t.Errorf("expected values read from file, got %q and %q", s.Password, s.User)
+	}
+	if s.Host != "db.local" {
+		t.Errorf("expected the variable to win over the file, got %q", s.Host)
+	}
+
+	os.Setenv("ENV_CONFIG_PASSWORD_FILE", "/nonexistent/secret")
+	err = ProcessX(&s, options)
+	v, ok := err.(*ParseError)
+	if !ok || v.FieldName != "Password" || v.KeyName != "ENV_CONFIG_PASSWORD_FILE" || !os.IsNotExist(v.Err) {
+		t.Errorf("expected ParseError for Password with the I/O error, got %v", err)
+	}
+}
+
+type validatedDB struct {
+	MinConns int
+	MaxConns int
+}
+
+func (db *validatedDB) Validate() error {
+	if db.MinConns > db.MaxConns {
+		return errors.New("MinConns exceeds MaxConns")
+	}
+	return nil
+}
+
+type validatedSpec struct {
+	DB      validatedDB
+	Replica *validatedDB
+	Port    int
+}
+
+func (s *validatedSpec) Validate() error {
+	if s.DB.MaxConns == 0 {
+		return errors.New("expected DB to be populated first")
+	}
+	if s.Port == 0 {
+		return errors.New("Port is required")
+	}
+	return nil
+}
+
+func TestValidator(t *testing.T) {
+	var s validatedSpec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DB_MINCONNS", "1")
+	os.Setenv("ENV_CONFIG_DB_MAXCONNS", "10")
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "0")
+	err := Process("env_config", &s)
+	if err == nil || err.Error() != "envconfig.validatedSpec: Port is required" {
+		t.Errorf("expected error from validatedSpec, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_REPLICA_MINCONNS", "5")
+	os.Setenv("ENV_CONFIG_REPLICA_MAXCONNS", "2")
+	err = Process("env_config", &s)
+	if err == nil || err.Error() != "envconfig.validatedDB: MinConns exceeds MaxConns" {
+		t.Errorf("expected nested validator to run first, got %v", err)
+	}
+}
+
+func TestRangeTags(t *testing.T) {
+	var s struct {
+		Port  int     `envconfig:"PORT" min:"1" max:"65535"`
+		Ratio float64 `min:"0" max:"1"`
+	}
+	os.Clearenv()
+	os.Setenv("PORT", "65535")
+	os.Setenv("ENV_CONFIG_RATIO", "0.5")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("PORT", "0")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok || v.FieldName != "Port" || v.Err.Error() != "value 0 is less than 1" {
+		t.Errorf("expected ParseError for Port, got %v", err)
+	}
+
+	os.Setenv("PORT", "80")
+	os.Setenv("ENV_CONFIG_RATIO", "1.5")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Ratio" {
+		t.Errorf("expected ParseError for Ratio, got %v", v)
+	}
+
+	var bad struct {
+		Port int `max:"lots"`
+	}
+	if err := Process("env_config", &bad); err == nil || !strings.Contains(err.Error(), `invalid max bound "lots"`) {
+		t.Errorf("expected error for malformed bound, got %v", err)
+	}
+}
+
+func TestSeededInterfaceField(t *testing.T) {
+	var s struct {
+		Level  encoding.TextUnmarshaler
+		Format interface{}
+		Other  interface{}
+	}
+	level := &logLevel{}
+	s.Level = level
+	s.Format = NewEnum("json", "text")
+
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_LEVEL", "warn")
+	os.Setenv("ENV_CONFIG_FORMAT", "TEXT")
+	os.Setenv("ENV_CONFIG_OTHER", "ignored")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if level.value != "WARN" {
+		t.Errorf("expected %q, got %q", "WARN", level.value)
+	}
+	if e, ok := s.Format.(Enum); !ok || e.String() != "text" {
+		t.Errorf("expected enum set to text, got %#v", s.Format)
+	}
+	if s.Other != nil {
+		t.Errorf("expected nil interface to be left alone, got %#v", s.Other)
+	}
+
+	os.Setenv("ENV_CONFIG_FORMAT", "xml")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Format" {
+		t.Errorf("expected ParseError for Format, got %v", v)
+	}
+}
+
+type logLevel struct {
+	value string
+}
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	l.value = strings.ToUpper(string(text))
+	return nil
+}
+
+func TestPatternTag(t *testing.T) {
+	var s struct {
+		Email  string   `envconfig:"EMAIL" pattern:"^[^@]+@[^@]+$"`
+		Admins []string `pattern:"^[a-z]+$"`
+	}
+	os.Clearenv()
+	os.Setenv("EMAIL", "kelsey@example.com")
+	os.Setenv("ENV_CONFIG_ADMINS", "rob,ken")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("EMAIL", "kelsey")
+	err := Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Email" || v.Value != "kelsey" {
+		t.Errorf("expected ParseError for Email, got %v", err)
+	}
+
+	os.Setenv("EMAIL", "kelsey@example.com")
+	os.Setenv("ENV_CONFIG_ADMINS", "rob,Ken")
+	err = Process("env_config", &s)
+	if v, ok := err.(*ParseError); !ok || v.FieldName != "Admins" || !strings.Contains(v.Err.Error(), `element 1: "Ken"`) {
+		t.Errorf("expected ParseError for element 1 of Admins, got %v", err)
+	}
+
+	var bad struct {
+		Name string `pattern:"[a-"`
+	}
+	if err := Process("env_config", &bad); err == nil || !strings.Contains(err.Error(), "invalid pattern") {
+		t.Errorf("expected error for malformed pattern, got %v", err)
+	}
+}
+
+func TestOneOfTag(t *testing.T) {
+	var s struct {
+		LogLevel string   `envconfig:"LOGLEVEL" oneof:"debug info warn error"`
+		Modes    []string `oneof:"fast safe" oneof_ci:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("LOGLEVEL", "warn")
+	os.Setenv("ENV_CONFIG_MODES", "FAST,safe")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !reflect.DeepEqual(s.Modes, []string{"fast", "safe"}) {
+		t.Errorf("expected %v, got %v", []string{"fast", "safe"}, s.Modes)
+	}
+
+	os.Setenv("LOGLEVEL", "WARN")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok || v.FieldName != "LogLevel" || !strings.Contains(v.Err.Error(), "debug, info, warn, error") {
+		t.Errorf("expected ParseError listing the allowed values, got %v", err)
+	}
+
+	os.Setenv("LOGLEVEL", "info")
+	os.Setenv("ENV_CONFIG_MODES", "fast,reckless")
+	if v, ok := Process("env_config", &s).(*ParseError); !ok || v.FieldName != "Modes" {
+		t.Errorf("expected ParseError for Modes, got %v", v)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_oneof .}}\n{{end}}"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "debug, info, warn, error\nfast, safe\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestOptionsNow(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := Options{Prefix: "env_config", Now: func() time.Time { return now }}
+
+	type spec struct {
+		Started  time.Time  `default:"now"`
+		Updated  *time.Time `default:"now"`
+		Deadline time.Time  `validate:"future"`
+		Epoch    time.Time  `validate:"past"`
+	}
+	if err := CheckSpec((*spec)(nil)); err != nil {
+		t.Errorf("expected default now to pass CheckSpec, got %v", err)
+	}
+
+	var s spec
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DEADLINE", "2020-01-03T00:00:00Z")
+	os.Setenv("ENV_CONFIG_EPOCH", "1970-01-01T00:00:00Z")
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Started.Equal(now) || s.Updated == nil || !s.Updated.Equal(now) {
+		t.Errorf("expected %v, got %v and %v", now, s.Started, s.Updated)
+	}
+
+	os.Setenv("ENV_CONFIG_DEADLINE", "2020-01-01T00:00:00Z")
+	err := ProcessX(&s, options)
+	if v, ok := err.(*ValidationError); !ok || v.FieldName != "Deadline" || v.Rule != "future" {
+		t.Errorf("expected ValidationError for Deadline, got %v", err)
+	}
+
+	var bad struct {
+		Count int `validate:"future"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for future on an int")
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	var s struct {
+		Hosts []string `maxbytes:"16"`
+		Name  string   `maxbytes:"4"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOSTS", "a,b,c,d,e,f,g,h")
+	os.Setenv("ENV_CONFIG_NAME", "abcd")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_HOSTS", strings.Repeat("a,", 1000))
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok || v.FieldName != "Hosts" || v.Err.Error() != "value is 2000 bytes, more than maxbytes 16" {
+		t.Errorf("expected ParseError for Hosts, got %v", err)
+	}
+
+	var bad struct {
+		Port int `maxbytes:"10"`
+	}
+	if err := Process("env_config", &bad); err == nil {
+		t.Error("expected error for maxbytes on an int")
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type spec struct {
+		Port     int
+		Debug    bool
+		Rate     float64
+		Name     string
+		Timeout  time.Duration
+		Hosts    []string
+		Limits   map[string]int `delimiter:";" separator:"="`
+		Started  time.Time
+		Level    *int
+		Skipped  string `ignored:"true"`
+		Database struct {
+			URL string `envconfig:"DATABASE_URL"`
+		}
+	}
+	in := spec{
+		Port:    8080,
+		Debug:   true,
+		Rate:    0.25,
+		Name:    "it's me",
+		Timeout: 90 * time.Second,
+		Hosts:   []string{"a", "b"},
+		Limits:  map[string]int{"x": 1, "y": 2},
+		Started: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Skipped: "secret",
+	}
+	in.Database.URL = "postgres://db/app"
+
+	vars, err := Marshal("env_config", &in)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	os.Clearenv()
+	for _, v := range vars {
+		if v.Key == "ENV_CONFIG_SKIPPED" || v.Key == "ENV_CONFIG_LEVEL" {
+			t.Errorf("expected %s to be left out", v.Key)
+		}
+		os.Setenv(v.Key, v.Value)
+	}
+	if got := os.Getenv("ENV_CONFIG_LIMITS"); got != "x=1;y=2" {
+		t.Errorf("expected %q, got %q", "x=1;y=2", got)
+	}
+
+	var out spec
+	if err := Process("env_config", &out); err != nil {
+		t.Fatal(err.Error())
+	}
+	in.Skipped = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("expected round trip to give %+v, got %+v", in, out)
+	}
+
+	text, err := MarshalText("env_config", &in)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	for _, want := range []string{"ENV_CONFIG_PORT=8080\n", `ENV_CONFIG_NAME='it'\''s me'` + "\n", "ENV_CONFIG_DATABASE_DATABASE_URL=postgres://db/app\n"} {
+		if !strings.Contains(string(text), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, text)
+		}
+	}
+}
+
+func TestProcessWithConsumed(t *testing.T) {
+	var s struct {
+		Port    int    `default:"80"`
+		Host    string `envconfig:"SERVICE_HOST"`
+		Debug   bool
+		Feature bool `negate:"NO_FEATURE"`
+	}
+	os.Clearenv()
+	os.Setenv("SERVICE_HOST", "example.com")
+	os.Setenv("ENV_CONFIG_DEBUG", "true")
+	os.Setenv("ENV_CONFIG_UNUSED", "x")
+
+	consumed, err := ProcessWithConsumed("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []string{"ENV_CONFIG_DEBUG", "SERVICE_HOST"}
+	if !reflect.DeepEqual(consumed, expected) {
+		t.Errorf("expected %v, got %v", expected, consumed)
+	}
+	if s.Host != "example.com" || !s.Debug || s.Port != 80 {
+		t.Errorf("expected normal processing, got %+v", s)
+	}
+}
+
+func TestExpandVars(t *testing.T) {
+	var s struct {
+		Greeting string
+		Home     string `default:"${BASE}/home"`
+		Literal  string
+		Port     int
+	}
+	os.Clearenv()
+	os.Setenv("USER", "gopher")
+	os.Setenv("BASE", "/srv")
+	os.Setenv("PORT", "8080")
+	os.Setenv("ENV_CONFIG_GREETING", "Hello ${USER}$UNSET!")
+	os.Setenv("ENV_CONFIG_LITERAL", "${} ${1x} $ ${USER")
+
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Greeting != "Hello ${USER}$UNSET!" {
+		t.Errorf("expected no expansion by default, got %q", s.Greeting)
+	}
+
+	os.Setenv("ENV_CONFIG_PORT", "$PORT")
+
+	options := Options{Prefix: "env_config", ExpandVars: true}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Greeting != "Hello gopher!" {
+		t.Errorf("expected %q, got %q", "Hello gopher!", s.Greeting)
+	}
+	if s.Home != "/srv/home" {
+		t.Errorf("expected %q, got %q", "/srv/home", s.Home)
+	}
+	if s.Literal != "${} ${1x} $ ${USER" {
+		t.Errorf("expected malformed references left alone, got %q", s.Literal)
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+
+	options.LookupFunc = func(key string) (string, bool) {
+		if key == "USER" {
+			return "lookup", true
+		}
+		return lookupEnv(key)
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Greeting != "Hello lookup!" {
+		t.Errorf("expected references resolved through LookupFunc, got %q", s.Greeting)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	var s struct {
+		Port     int
+		MaxConns int      `split_words:"true"`
+		User     string   `envconfig:"DB_USER"`
+		Hosts    []string `indexed:"true"`
+		Nested   struct {
+			Name string
+		}
+		Debug bool `negate:"ENV_CONFIG_QUIET"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+	os.Setenv("ENV_CONFIG_MAX_CONNS", "10")
+	os.Setenv("ENV_CONFIG_DB_USER", "gopher")
+	os.Setenv("ENV_CONFIG_HOSTS_0", "a")
+	os.Setenv("ENV_CONFIG_NESTED_NAME", "n")
+	os.Setenv("ENV_CONFIG_QUIET", "false")
+	os.Setenv("OTHER_PROT", "1")
+
+	options := Options{Prefix: "env_config", Strict: true}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_PROT", "8080")
+	os.Setenv("ENV_CONFIG_MAXCONNS", "10")
+	if err := Process("env_config", &s); err != nil {
+		t.Errorf("expected unknown variables to be ignored by default, got %v", err)
+	}
+	err := ProcessX(&s, options)
+	expected := "unknown environment variables: ENV_CONFIG_MAXCONNS, ENV_CONFIG_PROT"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+func TestValuePrecedence(t *testing.T) {
+	f, err := ioutil.TempFile("", "envconfig")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, "file")
+	f.Close()
+
+	type spec struct {
+		Value   string    `envconfig:"ALT_VALUE" default:"default"`
+		Started time.Time `default:"now"`
+		Count   int       `default:"@zero"`
+		Kept    int
+	}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	options := Options{Prefix: "env_config", FileFallback: true, Now: func() time.Time { return now }}
+
+	rungs := []struct {
+		env      map[string]string
+		expected string
+	}{
+		{map[string]string{"ENV_CONFIG_ALT_VALUE": "env", "ALT_VALUE": "alt", "ALT_VALUE_FILE": f.Name()}, "env"},
+		{map[string]string{"ALT_VALUE": "alt", "ALT_VALUE_FILE": f.Name()}, "alt"},
+		{map[string]string{"ALT_VALUE_FILE": f.Name()}, "file"},
+		{map[string]string{}, "default"},
+	}
+	for _, rung := range rungs {
+		os.Clearenv()
+		for k, v := range rung.env {
+			os.Setenv(k, v)
+		}
+		s := spec{Count: 5, Kept: 7}
+		if err := ProcessX(&s, options); err != nil {
+			t.Fatal(err.Error())
+		}
+		if s.Value != rung.expected {
+			t.Errorf("expected %q, got %q", rung.expected, s.Value)
+		}
+		if !s.Started.Equal(now) {
+			t.Errorf("expected computed default %v, got %v", now, s.Started)
+		}
+		if s.Count != 0 {
+			t.Errorf("expected @zero to reset Count, got %d", s.Count)
+		}
+		if s.Kept != 7 {
+			t.Errorf("expected unconfigured field to be left alone, got %d", s.Kept)
+		}
+	}
+
+	var required struct {
+		Literal  string    `required:"true" default:"x"`
+		Computed time.Time `required:"true" default:"now"`
+		Zero     int       `required:"true" default:"@zero"`
+	}
+	os.Clearenv()
+	err = ProcessX(&required, Options{Prefix: "env_config", AllErrors: true})
+	expected := "required key ENV_CONFIG_COMPUTED missing value\nrequired key ENV_CONFIG_ZERO missing value"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	var seeded struct {
+		Port int `required:"true"`
+	}
+	os.Setenv("ENV_CONFIG_JSON", `{"Port": 0}`)
+	if err := ProcessWithJSONSeed("env_config", "ENV_CONFIG_JSON", &seeded); err != nil {
+		t.Errorf("expected seed to satisfy required, got %v", err)
+	}
+}
+
+func TestTagName(t *testing.T) {
+	var s struct {
+		Port   int    `env:"SERVER_PORT,required"`
+		Host   string `envconfig:"IGNORED_HOST" default:"localhost"`
+		Nested struct {
+			User string `env:"DB_USER"`
+		}
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_SERVER_PORT", "8080")
+	os.Setenv("IGNORED_HOST", "example.com")
+	os.Setenv("ENV_CONFIG_NESTED_DB_USER", "gopher")
+
+	if err := ProcessX(&s, Options{Prefix: "env_config", TagName: "env"}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 {
+		t.Errorf("expected %d, got %d", 8080, s.Port)
+	}
+	if s.Host != "localhost" {
+		t.Errorf("expected the envconfig tag to be ignored, got %q", s.Host)
+	}
+	if s.Nested.User != "gopher" {
+		t.Errorf("expected %q, got %q", "gopher", s.Nested.User)
+	}
+
+	options := Options{Prefix: "env_config", TagName: "env"}
+	vars, err := MarshalX(&s, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if vars[0].Key != "ENV_CONFIG_SERVER_PORT" {
+		t.Errorf("expected %s, got %s", "ENV_CONFIG_SERVER_PORT", vars[0].Key)
+	}
+
+	var buf bytes.Buffer
+	usageOptions := UsageOptions{Prefix: "env_config", TagName: "env", Out: &buf, Format: DefaultListFormat}
+	if err := UsagefX(&s, usageOptions); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), "ENV_CONFIG_NESTED_DB_USER") {
+		t.Errorf("expected usage to use the env tag, got:\n%s", buf.String())
+	}
+
+	type duplicate struct {
+		A string `env:"SAME"`
+		B string `env:"SAME"`
+	}
+	if err := CheckSpecX((*duplicate)(nil), options); err == nil || !strings.Contains(err.Error(), "both use key ENV_CONFIG_SAME") {
+		t.Errorf("expected duplicate key error, got %v", err)
+	}
+
+	var required struct {
+		Token string `env:"API_TOKEN" required:"true"`
+	}
+	os.Clearenv()
+	missing, err := MissingRequiredX(&required, options)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(missing) != 1 || missing[0].Key != "ENV_CONFIG_API_TOKEN" {
+		t.Errorf("expected ENV_CONFIG_API_TOKEN to be missing, got %+v", missing)
+	}
+}
+
+func TestNetTypes(t *testing.T) {
+	var s struct {
+		Bind    net.IP
+		Peers   []net.IP
+		Subnet  net.IPNet
+		Allowed *net.IPNet
+		MAC     net.HardwareAddr
+		MACs    []net.HardwareAddr `pipe:"trim"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_BIND", "10.0.0.1")
+	os.Setenv("ENV_CONFIG_PEERS", "10.0.0.2,::1")
+	os.Setenv("ENV_CONFIG_SUBNET", "192.168.1.17/24")
+	os.Setenv("ENV_CONFIG_ALLOWED", "10.0.0.0/8")
+	os.Setenv("ENV_CONFIG_MAC", "00:1a:2b:3c:4d:5e")
+	os.Setenv("ENV_CONFIG_MACS", "00:1a:2b:3c:4d:5e, 00:1a:2b:3c:4d:5f")
+
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !s.Bind.Equal(net.ParseIP("10.0.0.1")) {
+		t.Errorf("expected %v, got %v", "10.0.0.1", s.Bind)
+	}
+	if len(s.Peers) != 2 || !s.Peers[1].Equal(net.IPv6loopback) {
+		t.Errorf("unexpected peers %v", s.Peers)
+	}
+	if s.Subnet.String() != "192.168.1.0/24" {
+		t.Errorf("expected masked network %v, got %v", "192.168.1.0/24", s.Subnet.String())
+	}
+	if s.Allowed == nil || s.Allowed.String() != "10.0.0.0/8" {
+		t.Errorf("expected %v, got %v", "10.0.0.0/8", s.Allowed)
+	}
+	if s.MAC.String() != "00:1a:2b:3c:4d:5e" {
+		t.Errorf("expected %v, got %v", "00:1a:2b:3c:4d:5e", s.MAC)
+	}
+	if len(s.MACs) != 2 || s.MACs[1].String() != "00:1a:2b:3c:4d:5f" {
+		t.Errorf("unexpected MACs %v", s.MACs)
+	}
+
+	for key, field := range map[string]string{
+		"ENV_CONFIG_BIND":   "Bind",
+		"ENV_CONFIG_SUBNET": "Subnet",
+		"ENV_CONFIG_MAC":    "MAC",
+	} {
+		os.Setenv(key, "bogus")
+		err := Process("env_config", &s)
+		if v, ok := err.(*ParseError); !ok || v.FieldName != field {
+			t.Errorf("expected ParseError for %s, got %v", field, err)
+		}
+		os.Unsetenv(key)
+	}
+}
+
+func TestTransformFunc(t *testing.T) {
+	var s struct {
+		Host  string
+		Level string `default:" INFO "`
+		Port  int
+		Peers []string `indexed:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", " Example.COM\n")
+	os.Setenv("ENV_CONFIG_PORT", " 8080 ")
+	os.Setenv("ENV_CONFIG_PEERS_0", " A ")
+
+	var keys []string
+	options := Options{
+		Prefix: "env_config",
+		TransformFunc: func(key, raw string) string {
+			keys = append(keys, key)
+			raw = strings.TrimSpace(raw)
+			if key == "ENV_CONFIG_PORT" {
+				return raw
+			}
+			return strings.ToLower(raw)
+		},
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Host != "example.com" || s.Level != "info" || s.Port != 8080 {
+		t.Errorf("unexpected values %+v", s)
+	}
+	if len(s.Peers) != 1 || s.Peers[0] != "a" {
+		t.Errorf("expected %v, got %v", []string{"a"}, s.Peers)
+	}
+	expected := []string{"ENV_CONFIG_HOST", "ENV_CONFIG_LEVEL", "ENV_CONFIG_PORT", "ENV_CONFIG_PEERS_0"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+}
+
+func TestTrimTag(t *testing.T) {
+	var s struct {
+		Password string         `trim:"true"`
+		Port     int            `trim:"true"`
+		Hosts    []string       `trim:"true"`
+		Weights  map[string]int `trim:"true"`
+		Raw      string
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PASSWORD", " s3cret\n")
+	os.Setenv("ENV_CONFIG_PORT", "8080\n")
+	os.Setenv("ENV_CONFIG_HOSTS", " a , b\n")
+	os.Setenv("ENV_CONFIG_WEIGHTS", "x: 1, y :2")
+	os.Setenv("ENV_CONFIG_RAW", " untouched\n")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Password != "s3cret" || s.Port != 8080 {
+		t.Errorf("unexpected values %+v", s)
+	}
+	if !reflect.DeepEqual(s.Hosts, []string{"a", "b"}) {
+		t.Errorf("expected %v, got %v", []string{"a", "b"}, s.Hosts)
+	}
+	if !reflect.DeepEqual(s.Weights, map[string]int{"x": 1, "y": 2}) {
+		t.Errorf("expected %v, got %v", map[string]int{"x": 1, "y": 2}, s.Weights)
+	}
+	if s.Raw != " untouched\n" {
+		t.Errorf("expected untagged fields to be left alone, got %q", s.Raw)
+	}
+}
+
+func TestRequiredNotEmpty(t *testing.T) {
+	var s struct {
+		User     string `required:"true"`
+		Password string `required_notempty:"true"`
+		Token    string `required_notempty:"true"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_USER", "")
+	os.Setenv("ENV_CONFIG_PASSWORD", "")
+
+	err := ProcessX(&s, Options{Prefix: "env_config", AllErrors: true})
+	expected := "required key ENV_CONFIG_PASSWORD set to empty value\nrequired key ENV_CONFIG_TOKEN missing value"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	missing, err := MissingRequired("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if len(missing) != 2 || missing[0].Name != "Password" || !missing[0].Required {
+		t.Errorf("unexpected missing fields %+v", missing)
+	}
+
+	os.Setenv("ENV_CONFIG_PASSWORD", "s3cret")
+	os.Setenv("ENV_CONFIG_TOKEN", "t")
+	if err := Process("env_config", &s); err != nil {
+		t.Errorf("expected empty User to satisfy required, got %v", err)
+	}
+}
+
+func TestRequiredIf(t *testing.T) {
+	var s struct {
+		TLSCert    string `required_if:"TLSEnabled=true"`
+		TLSKey     string `required_if:"ENV_CONFIG_TLSENABLED=1" default:"key.pem"`
+		Backend    string
+		RedisURL   string `required_if:"Backend=redis"`
+		TLSEnabled bool
+	}
+	os.Clearenv()
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_TLSENABLED", "true")
+	os.Setenv("ENV_CONFIG_BACKEND", "redis")
+	err := Process("env_config", &s)
+	expected := "required key ENV_CONFIG_TLSCERT missing value\nrequired key ENV_CONFIG_REDISURL missing value\n"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	os.Setenv("ENV_CONFIG_TLSCERT", "cert.pem")
+	os.Setenv("ENV_CONFIG_REDISURL", "redis://localhost")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var bad struct {
+		Cert string `required_if:"Missing=true"`
+	}
+	if err := Process("env_config", &bad); err == nil || !strings.Contains(err.Error(), "unknown field Missing") {
+		t.Errorf("expected error for unknown field, got %v", err)
+	}
+	var badValue struct {
+		Enabled bool
+		Cert    string `required_if:"Enabled=maybe"`
+	}
+	if err := CheckSpec(&badValue); err == nil {
+		t.Error("expected error for a value of the wrong type")
+	}
+}
+
+func TestGroups(t *testing.T) {
+	var s struct {
+		APIKey     string `group:"auth"`
+		OAuthToken string `group:"auth"`
+		Redis      string `group:"backend"`
+		Memcached  string `group:"backend" default:"localhost:11211"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_APIKEY", "key")
+	os.Setenv("ENV_CONFIG_REDIS", "localhost:6379")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	os.Setenv("ENV_CONFIG_OAUTHTOKEN", "token")
+	os.Unsetenv("ENV_CONFIG_REDIS")
+	err := ProcessX(&s, Options{Prefix: "env_config", AllErrors: true})
+	expected := "group auth: only one of ENV_CONFIG_APIKEY, ENV_CONFIG_OAUTHTOKEN may be set\n" +
+		"group backend: one of ENV_CONFIG_REDIS, ENV_CONFIG_MEMCACHED must be set"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+}
+
+type squashedDB struct {
+	Host     string
+	MaxConns int    `split_words:"true"`
+	User     string `envconfig:"DB_USER"`
+}
+
+func TestSquash(t *testing.T) {
+	var s struct {
+		DB    squashedDB `squash:"true"`
+		Cache struct {
+			Host string
+		}
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_HOST", "db.local")
+	os.Setenv("ENV_CONFIG_MAX_CONNS", "10")
+	os.Setenv("ENV_CONFIG_DB_USER", "gopher")
+	os.Setenv("ENV_CONFIG_CACHE_HOST", "cache.local")
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.DB.Host != "db.local" || s.DB.MaxConns != 10 || s.DB.User != "gopher" {
+		t.Errorf("unexpected squashed values %+v", s.DB)
+	}
+	if s.Cache.Host != "cache.local" {
+		t.Errorf("expected %q, got %q", "cache.local", s.Cache.Host)
+	}
+
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}\n{{end}}"); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := "ENV_CONFIG_HOST\nENV_CONFIG_MAX_CONNS\nENV_CONFIG_DB_USER\nENV_CONFIG_CACHE_HOST\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestNestedKeyOverride(t *testing.T) {
+	var s struct {
+		Database struct {
+			Host     string
+			MaxConns int
+		} `envconfig:"DB"`
+		ReadReplica struct {
+			Host string
+		}
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_DB_HOST", "db.local")
+	os.Setenv("ENV_CONFIG_DB_MAX_CONNS", "10")
+	os.Setenv("ENV_CONFIG_READ_REPLICA_HOST", "replica.local")
+	if err := ProcessX(&s, Options{Prefix: "env_config", SplitWords: true}); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Database.Host != "db.local" || s.Database.MaxConns != 10 {
+		t.Errorf("unexpected database values %+v", s.Database)
+	}
+	if s.ReadReplica.Host != "replica.local" {
+		t.Errorf("expected %q, got %q", "replica.local", s.ReadReplica.Host)
+	}
+
+	buf := new(bytes.Buffer)
+	err := UsagefX(&s, UsageOptions{
+		Prefix:     "env_config",
+		SplitWords: true,
+		Out:        buf,
+		Format:     "{{range .}}{{usage_key .}}\n{{end}}",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := "ENV_CONFIG_DB_HOST\nENV_CONFIG_DB_MAX_CONNS\nENV_CONFIG_READ_REPLICA_HOST\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestExpandVarsDefaults(t *testing.T) {
+	var s struct {
+		CacheDir string `default:"${HOME}/cache"`
+		URL      string `default:"http://${HOST}:${PORT}/"`
+		Literal  string `default:"${HOME}"`
+	}
+	env := map[string]string{"HOME": "/home/gopher", "HOST": "localhost"}
+	options := Options{
+		Prefix:     "env_config",
+		ExpandVars: true,
+		LookupFunc: func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		},
+	}
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.CacheDir != "/home/gopher/cache" {
+		t.Errorf("expected %q, got %q", "/home/gopher/cache", s.CacheDir)
+	}
+	if s.URL != "http://localhost:/" {
+		t.Errorf("expected unresolved references to expand to nothing, got %q", s.URL)
+	}
+
+	options.ExpandVars = false
+	if err := ProcessX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Literal != "${HOME}" {
+		t.Errorf("expected defaults to be literal without ExpandVars, got %q", s.Literal)
+	}
+}
+
+func TestSensitiveParseError(t *testing.T) {
+	var s struct {
+		Token int    `sensitive:"true"`
+		Key   string `sensitive:"true" pattern:"^[a-f0-9]+$"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_TOKEN", "s3cr3t")
+	err := Process("env_config", &s)
+	v, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected ParseError, got %v", err)
+	}
+	if v.Value != sensitiveMask {
+		t.Errorf("expected %q, got %q", sensitiveMask, v.Value)
+	}
+	if strings.Contains(err.Error(), "s3cr3t") {
+		t.Errorf("expected value to be masked, got %q", err)
+	}
+	var numErr *strconv.NumError
+	if !errors.As(v.Err, &numErr) {
+		t.Errorf("expected the underlying error to be kept, got %v", err)
+	}
+
+	os.Setenv("ENV_CONFIG_TOKEN", "1")
+	os.Setenv("ENV_CONFIG_KEY", "NOT-HEX")
+	if err := Process("env_config", &s); err == nil || strings.Contains(err.Error(), "NOT-HEX") {
+		t.Errorf("expected masked pattern error, got %v", err)
+	}
+
+	var validated struct {
+		Mode  string `sensitive:"true" enum:"a,b"`
+		Level int    `sensitive:"true" validate:"min=100000"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_MODE", "supersecret")
+	if err := Process("env_config", &validated); err == nil || strings.Contains(err.Error(), "supersecret") {
+		t.Errorf("expected masked enum error, got %v", err)
+	}
+	os.Setenv("ENV_CONFIG_MODE", "a")
+	os.Setenv("ENV_CONFIG_LEVEL", "31337")
+	if err := Process("env_config", &validated); err == nil || strings.Contains(err.Error(), "31337") {
+		t.Errorf("expected masked min error, got %v", err)
+	}
+
+	os.Clearenv()
+	env := map[string]string{"ENV_CONFIG_TOKEN": "42", "ENV_CONFIG_KEY": "abc"}
+	options := Options{
+		Prefix:      "env_config",
+		OmitSecrets: true,
+		LookupFunc:  func(key string) (string, bool) { v, ok := env[key]; return v, ok },
+	}
+	if err := ExportToEnvX(&s, options); err != nil {
+		t.Fatal(err.Error())
+	}
+	for key := range env {
+		if _, ok := os.LookupEnv(key); ok {
+			t.Errorf("expected %s to be omitted", key)
+		}
+	}
+}
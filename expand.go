@@ -0,0 +1,79 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import "strings"
+
+// expandVars replaces ${NAME} and $NAME references in value with the values
+// lookup returns for them, or with nothing when they are unset, as a shell
+// would. A reference whose name is empty or malformed, such as ${} or an
+// unterminated ${, is left as written, and so is a $ not followed by a name.
+func expandVars(value string, lookup func(string) (string, bool)) string {
+	if !strings.Contains(value, "$") {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			b.WriteByte(value[i])
+			continue
+		}
+
+		var name string
+		var end int
+		if value[i+1] == '{' {
+			closing := strings.IndexByte(value[i+2:], '}')
+			if closing < 0 {
+				b.WriteByte(value[i])
+				continue
+			}
+			name, end = value[i+2:i+2+closing], i+2+closing+1
+			if !isVarName(name) {
+				b.WriteByte(value[i])
+				continue
+			}
+		} else {
+			end = i + 1
+			for end < len(value) && isVarNameByte(value[end], end == i+1) {
+				end++
+			}
+			name = value[i+1 : end]
+			if name == "" {
+				b.WriteByte(value[i])
+				continue
+			}
+		}
+
+		expanded, _ := lookup(name)
+		b.WriteString(expanded)
+		i = end - 1
+	}
+	return b.String()
+}
+
+// isVarName reports whether name is a valid variable name: a letter or
+// underscore followed by letters, digits and underscores.
+func isVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isVarNameByte(name[i], i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVarNameByte(c byte, first bool) bool {
+	switch {
+	case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		return true
+	case '0' <= c && c <= '9':
+		return !first
+	}
+	return false
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"encoding"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//nolint:gochecknoglobals
+var passwordType = reflect.TypeOf(Password(""))
+
+// ExportToEnv processes the specified struct and then sets each field's
+// environment variable to its resolved value, so that child processes see
+// defaults computed by the parent and not just the raw environment. Secrets
+// are exported as they are; use ExportToEnvX with OmitSecrets to leave them
+// out.
+func ExportToEnv(prefix string, spec interface{}) error {
+	return ExportToEnvX(spec, Options{Prefix: prefix})
+}
+
+// ExportToEnvX is like ExportToEnv but takes Options.
+func ExportToEnvX(spec interface{}, options Options) error {
+	if err := ProcessX(spec, options); err != nil {
+		return err
+	}
+
+	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return err
+	}
+	// gatherInfo allocates the struct pointers NilEmptyStructs left nil;
+	// nothing beneath them is exported, and they are set back to nil
+	defer func() {
+		for _, info := range infos {
+			for _, ptr := range info.Allocated {
+				ptr.Set(reflect.Zero(ptr.Type()))
+			}
+		}
+	}()
+
+	for _, info := range infos {
+		if len(info.Allocated) > 0 {
+			continue
+		}
+		if options.OmitSecrets && (info.Sensitive || info.Field.Type() == passwordType) {
+			continue
+		}
+		_, src, err := resolveValue(info, options)
+		if err != nil {
+			return err
+		}
+		if src == sourceZero && isZero(info.Field) {
+			// nothing was resolved, so there is nothing to pass on
+			continue
+		}
+		key := options.mapKey(info.Key)
+		value, err := formatValue(info.Field, info.Tags)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", key, err)
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatValue renders field in the form processField reads back.
+func formatValue(field reflect.Value, tags reflect.StructTag) (string, error) {
+	if field.Type() == passwordType {
+		return field.Interface().(Password).Reveal(), nil
+	}
+	if isTime(field.Type()) && tags.Get("format") == "unix" {
+		return strconv.FormatInt(field.Interface().(time.Time).Unix(), 10), nil
+	}
+	if layout := tags.Get("layout"); layout != "" && field.Type() == timeType {
+		loc, err := time.LoadLocation(tags.Get("location"))
+		if err != nil {
+			return "", err
+		}
+		return field.Interface().(time.Time).In(loc).Format(layout), nil
+	}
+
+	switch v := field.Interface().(type) {
+	case time.Duration:
+		return v.String(), nil
+	case ConnString:
+		// String redacts the password, which would not read back
+		return v.URL(), nil
+	case KeyValues:
+		return v.format(mapSeparator(tags), listDelimiter(tags)), nil
+	case Bytes:
+		// String always uses base64, whatever the encoding tag says
+		enc := tags.Get("encoding")
+		if enc == "" {
+			enc = "base64"
+		}
+		return encodeBytes(v, enc)
+	case net.IPNet:
+		return v.String(), nil
+	case encoding.TextMarshaler:
+		text, err := v.MarshalText()
+		return string(text), err
+	case fmt.Stringer:
+		return v.String(), nil
+	}
+
+	switch field.Kind() {
+	case reflect.Ptr:
+		if field.IsNil() {
+			return "", nil
+		}
+		return formatValue(field.Elem(), tags)
+	case reflect.Slice, reflect.Array:
+		if isBytes(field.Type()) {
+			data := make([]byte, field.Len())
+			reflect.Copy(reflect.ValueOf(data), field)
+			return encodeBytes(data, bytesEncoding(tags))
+		}
+		items := make([]string, field.Len())
+		for i := range items {
+			item, err := formatValue(field.Index(i), tags)
+			if err != nil {
+				return "", err
+			}
+			items[i] = item
+		}
+		return strings.Join(items, listDelimiter(tags)), nil
+	case reflect.Map:
+		items := make([]string, 0, field.Len())
+		for _, k := range field.MapKeys() {
+			key, err := formatValue(k, tags)
+			if err != nil {
+				return "", err
+			}
+			value, err := formatValue(field.MapIndex(k), tags)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, key+mapSeparator(tags)+value)
+		}
+		sort.Strings(items)
+		return strings.Join(items, listDelimiter(tags)), nil
+	case reflect.Struct, reflect.Interface, reflect.Chan, reflect.Func:
+		return "", fmt.Errorf("cannot format %s", field.Type())
+	}
+	return fmt.Sprint(field.Interface()), nil
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"io/ioutil"
+	"strings"
+)
+
+// lookupFile resolves the value of info from the file named by a KEY_FILE
+// variable, as used for secrets mounted into containers. Failing to read the
+// file is reported as a ParseError against the KEY_FILE variable.
+func lookupFile(info varInfo, options Options) (value string, ok bool, err error) {
+	var key, path string
+	for _, k := range lookupKeys(info, options) {
+		key = k + "_FILE"
+		if path, ok = options.lookup(key); ok {
+			break
+		}
+	}
+	if !ok {
+		return "", false, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", false, &ParseError{
+			KeyName:   key,
+			FieldName: info.Name,
+			TypeName:  info.Field.Type().String(),
+			Value:     path,
+			Err:       err,
+		}
+	}
+	value = strings.TrimSuffix(string(data), "\n")
+	return strings.TrimSuffix(value, "\r"), true, nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DefaultMaxIndex is the highest index probed for indexed slices when
+// Options.MaxIndex is not set.
+const DefaultMaxIndex = 100
+
+// checkIndexedTag validates the indexed tag of a struct field: it must be
+// "true" or "sparse" and is only allowed on slices.
+func checkIndexedTag(ftype reflect.StructField) error {
+	mode := ftype.Tag.Get("indexed")
+	if mode == "" {
+		return nil
+	}
+	if mode != "true" && mode != "sparse" {
+		return fmt.Errorf("field %s: invalid indexed mode %q", ftype.Name, mode)
+	}
+	if ftype.Type.Kind() != reflect.Slice {
+		return fmt.Errorf("field %s: indexed requires a slice field, got %s", ftype.Name, ftype.Type)
+	}
+	return nil
+}
+
+// processIndexed populates a slice field tagged indexed from one variable
+// per element, KEY_0, KEY_1 and so on. By default the scan stops at the
+// first missing index; with indexed:"sparse" gaps are left as zero values
+// and the slice is sized to the highest index found. Each element goes
+// through the same expansion, transform and maxbytes, range, pattern and
+// oneof checks as a plain value. It reports whether any element was found, so the caller can fall
+// back to the plain key.
+func processIndexed(info varInfo, options Options) (bool, error) {
+	mode := info.Tags.Get("indexed")
+	if mode == "" {
+		return false, nil
+	}
+
+	limit := options.MaxIndex
+	if limit <= 0 {
+		limit = DefaultMaxIndex
+	}
+
+	key := options.mapKey(info.Key)
+	values := make(map[int]string)
+	length := 0
+	for i := 0; i <= limit; i++ {
+		v, ok := options.lookup(indexedKey(key, i))
+		if !ok {
+			if mode == "sparse" {
+				continue
+			}
+			break
+		}
+		if options.ExpandVars {
+			v = expandVars(v, options.lookup)
+		}
+		if options.TransformFunc != nil {
+			v = options.TransformFunc(indexedKey(key, i), v)
+		}
+		values[i] = v
+		length = i + 1
+	}
+	if length == 0 {
+		return false, nil
+	}
+
+	sl := reflect.MakeSlice(info.Field.Type(), length, length)
+	for i, v := range values {
+		if err := parseValue(processField, v, sl.Index(i), info, options); err != nil {
+			return true, newParseError(info, indexedKey(key, i), v, fmt.Errorf("element %d: %w", i, err))
+		}
+	}
+	info.Field.Set(sl)
+	return true, nil
+}
+
+func indexedKey(key string, i int) string {
+	return fmt.Sprintf("%s_%d", key, i)
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import "reflect"
+
+// VarInfo describes the environment variable behind a field of a
+// specification.
+type VarInfo struct {
+	// Name is the name of the struct field.
+	Name string
+	// Key is the environment variable the field is read from.
+	Key string
+	// AltKey is the variable named by the envconfig tag, or the tag chosen
+	// with Options.TagName, if any.
+	AltKey string
+	// Type is the type of the struct field.
+	Type reflect.Type
+	// Default is the value of the default tag.
+	Default string
+	// Required reports whether the required or required_notempty tag is set.
+	Required bool
+	// Description is the value of the desc tag.
+	Description string
+	// Sensitive reports whether the sensitive tag is set.
+	Sensitive bool
+	// Tags holds all the struct tags of the field.
+	Tags reflect.StructTag
+}
+
+func (info varInfo) export(options Options) VarInfo {
+	return VarInfo{
+		Name:        info.Name,
+		Key:         options.mapKey(info.Key),
+		AltKey:      info.Alt,
+		Type:        info.Field.Type(),
+		Default:     info.Tags.Get("default"),
+		Required:    isRequired(info.Tags),
+		Description: info.Tags.Get("desc"),
+		Sensitive:   info.Sensitive,
+		Tags:        info.Tags,
+	}
+}
+
+// Gather returns a VarInfo for each variable the specified struct is read
+// from, in declaration order, as Process would compute its keys with
+// options. The prefix argument takes the place of options.Prefix. Like
+// Process, it allocates nil pointers to nested structs in spec, but reads
+// nothing from the environment.
+func Gather(prefix string, spec interface{}, options Options) ([]VarInfo, error) {
+	options.Prefix = prefix
+	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]VarInfo, len(infos))
+	for i, info := range infos {
+		vars[i] = info.export(options)
+	}
+	return vars, nil
+}
+
+// MissingRequired returns the fields of the specified struct that are
+// required, or whose required_if condition holds, but have neither a value in
+// the environment nor a literal default, or are set to the empty string
+// despite required_notempty. It is the data counterpart to the error Process
+// returns for them.
+func MissingRequired(prefix string, spec interface{}) ([]VarInfo, error) {
+	return MissingRequiredX(spec, Options{Prefix: prefix})
+}
+
+// MissingRequiredX is like MissingRequired but takes Options, so keys follow
+// TagName and KeyMapper and values are read through LookupFunc.
+func MissingRequiredX(spec interface{}, options Options) ([]VarInfo, error) {
+	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []VarInfo
+	for _, info := range infos {
+		required := isRequired(info.Tags)
+		if !required && info.Tags.Get("required_if") != "" {
+			required, err = requiredIfHolds(info, infos, options, func(ref varInfo) (reflect.Value, error) {
+				return resolvedField(ref, options)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !required {
+			continue
+		}
+
+		value, src, err := resolveValue(info, options)
+		if err != nil {
+			return nil, err
+		}
+		m := !src.satisfiesRequired()
+		if isRequired(info.Tags) {
+			m, _ = checkRequired(info, value, src)
+		}
+		if m {
+			missing = append(missing, info.export(options))
+		}
+	}
+	return missing, nil
+}
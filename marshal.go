@@ -0,0 +1,90 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EnvVar is an environment variable assignment produced by Marshal.
+type EnvVar struct {
+	Key   string
+	Value string
+}
+
+// Marshal is the inverse of Process: it returns an assignment for each field
+// of the specified struct, formatted so that Process reads it back into the
+// same value. Nil pointers, slices and maps are left out, as are zero values
+// whose text form does not parse back, such as an empty CronSchedule. Fields
+// tagged sensitive are masked.
+func Marshal(prefix string, spec interface{}) ([]EnvVar, error) {
+	return MarshalX(spec, Options{Prefix: prefix})
+}
+
+// MarshalX is like Marshal but takes Options, so keys follow TagName and
+// KeyMapper as they do for ProcessX.
+func MarshalX(spec interface{}, options Options) ([]EnvVar, error) {
+	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]EnvVar, 0, len(infos))
+	for _, info := range infos {
+		switch info.Field.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			if info.Field.IsNil() {
+				continue
+			}
+		}
+		if info.Sensitive {
+			vars = append(vars, EnvVar{Key: options.mapKey(info.Key), Value: sensitiveMask})
+			continue
+		}
+		value, err := formatValue(info.Field, info.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling %s: %w", info.Key, err)
+		}
+		if isZero(info.Field) && !parsesBack(value, info, options) {
+			continue
+		}
+		vars = append(vars, EnvVar{Key: options.mapKey(info.Key), Value: value})
+	}
+	return vars, nil
+}
+
+// parsesBack reports whether Process would accept value for info, trying it
+// on a fresh value of the field's type.
+func parsesBack(value string, info varInfo, options Options) bool {
+	fresh := reflect.New(info.Field.Type()).Elem()
+	return processField(value, fresh, info.Tags, options) == nil
+}
+
+// MarshalText is like Marshal but returns KEY=VALUE lines, with values
+// quoted for a POSIX shell, ready to be sourced.
+func MarshalText(prefix string, spec interface{}) ([]byte, error) {
+	vars, err := Marshal(prefix, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	for _, v := range vars {
+		fmt.Fprintf(&buf, "%s=%s\n", v.Key, shellQuote(v.Value))
+	}
+	return buf.Bytes(), nil
+}
+
+// shellQuote wraps s in single quotes unless it only holds characters that
+// are safe unquoted.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,:/@%+=") == "" {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
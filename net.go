@@ -0,0 +1,63 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+)
+
+//nolint:gochecknoglobals
+var (
+	ipType           = reflect.TypeOf(net.IP(nil))
+	ipNetType        = reflect.TypeOf(net.IPNet{})
+	hardwareAddrType = reflect.TypeOf(net.HardwareAddr(nil))
+)
+
+// isNet reports whether t is net.IP, net.IPNet or net.HardwareAddr, or a
+// pointer to one of them.
+func isNet(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == ipType || t == ipNetType || t == hardwareAddrType
+}
+
+// processNet parses value into a net.IP, net.IPNet or net.HardwareAddr
+// field. A CIDR is stored as the network it denotes, with the host bits
+// masked off.
+func processNet(value string, field reflect.Value) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(field.Type().Elem()))
+		}
+		field = field.Elem()
+	}
+
+	var v interface{}
+	switch field.Type() {
+	case ipType:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return fmt.Errorf("invalid IP address %q", value)
+		}
+		v = ip
+	case ipNetType:
+		_, n, err := net.ParseCIDR(value)
+		if err != nil {
+			return err
+		}
+		v = *n
+	case hardwareAddrType:
+		mac, err := net.ParseMAC(value)
+		if err != nil {
+			return err
+		}
+		v = mac
+	}
+	field.Set(reflect.ValueOf(v))
+	return nil
+}
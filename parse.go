@@ -0,0 +1,23 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+// Parse allocates a new T, populates it as Process does and returns it, so
+// that callers can write cfg, err := envconfig.Parse[Config]("myapp"). T must
+// be a struct type. On error the partially populated value is returned.
+func Parse[T any](prefix string) (T, error) {
+	var spec T
+	err := Process(prefix, &spec)
+	return spec, err
+}
+
+// MustParse is the same as Parse but panics if an error occurs.
+func MustParse[T any](prefix string) T {
+	spec, err := Parse[T](prefix)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
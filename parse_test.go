@@ -0,0 +1,49 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	type spec struct {
+		Port int    `required:"true"`
+		Host string `default:"localhost"`
+	}
+	os.Clearenv()
+	os.Setenv("ENV_CONFIG_PORT", "8080")
+
+	s, err := Parse[spec]("env_config")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Port != 8080 || s.Host != "localhost" {
+		t.Errorf("unexpected result %+v", s)
+	}
+
+	os.Clearenv()
+	if _, err := Parse[spec]("env_config"); err == nil {
+		t.Error("expected error for missing required variable")
+	}
+	if _, err := Parse[map[string]string]("env_config"); err != ErrInvalidSpecification {
+		t.Errorf("expected %v, got %v", ErrInvalidSpecification, err)
+	}
+}
+
+func TestMustParse(t *testing.T) {
+	os.Clearenv()
+	defer func() {
+		if err := recover(); err != nil {
+			return
+		}
+
+		t.Error("expected panic")
+	}()
+	MustParse[struct {
+		Port int `required:"true"`
+	}]("env_config")
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// pipeTransforms are the transforms the pipe tag can chain.
+//
+//nolint:gochecknoglobals
+var pipeTransforms = map[string]func(string) (string, error){
+	"trim":  func(s string) (string, error) { return strings.TrimSpace(s), nil },
+	"lower": func(s string) (string, error) { return strings.ToLower(s), nil },
+	"upper": func(s string) (string, error) { return strings.ToUpper(s), nil },
+	"unquote": func(s string) (string, error) {
+		if len(s) < 2 || !strings.ContainsRune(`"'`+"`", rune(s[0])) {
+			return s, nil
+		}
+		return strconv.Unquote(s)
+	},
+}
+
+// checkPipeTag validates that the pipe tag of a struct field only names
+// known transforms.
+func checkPipeTag(ftype reflect.StructField) error {
+	pipe := ftype.Tag.Get("pipe")
+	if pipe == "" {
+		return nil
+	}
+	for _, name := range strings.Split(pipe, ",") {
+		if _, ok := pipeTransforms[strings.TrimSpace(name)]; !ok {
+			return fmt.Errorf("field %s: unknown pipe transform %q", ftype.Name, name)
+		}
+	}
+	return nil
+}
+
+// applyPipe runs value through the comma-separated chain of transforms.
+func applyPipe(value, pipe string) (string, error) {
+	for _, name := range strings.Split(pipe, ",") {
+		name = strings.TrimSpace(name)
+		var err error
+		if value, err = pipeTransforms[name](value); err != nil {
+			return "", fmt.Errorf("pipe %s: %w", name, err)
+		}
+	}
+	return value, nil
+}
+
+// splitsValue reports whether processField splits the value for field into
+// elements, in which case the pipe is applied to each element instead.
+func splitsValue(field reflect.Value) bool {
+	typ := field.Type()
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+	default:
+		return false
+	}
+	if typ == urlValuesType || isNet(typ) || isBytes(typ) {
+		return false
+	}
+	return tagSetterFrom(field) == nil && decoderFrom(field) == nil && setterFrom(field) == nil &&
+		textUnmarshaler(field) == nil && binaryUnmarshaler(field) == nil
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import "reflect"
+
+// zeroDefault is the default tag value that resets a field to its zero value
+// when nothing else configures it.
+const zeroDefault = "@zero"
+
+// valueSource identifies where the value of a field comes from. The sources
+// are listed in order of precedence, and resolveValue is the one place that
+// applies it:
+//
+//	sourceEnv       the variable named by the field's key
+//	sourceAlt       the alternate key from the envconfig tag
+//	sourceFile      the KEY_FILE variant, with Options.FileFallback
+//	sourceSeed      a value set by the JSON seed of ProcessWithJSONSeed
+//	sourceDefault   the default tag
+//	sourceComputed  a default computed at processing time, such as "now"
+//	sourceZero      nothing; the field keeps its value, or is reset by "@zero"
+//
+// Options.LastWins swaps the first two. Only the sources up to and including
+// sourceDefault satisfy the required tag, so a literal default makes a
+// required field "required unless defaulted" without a tag of its own.
+type valueSource int
+
+const (
+	sourceEnv valueSource = iota
+	sourceAlt
+	sourceFile
+	sourceSeed
+	sourceDefault
+	sourceComputed
+	sourceZero
+)
+
+// fromEnvironment reports whether the value was read from a variable.
+func (src valueSource) fromEnvironment() bool {
+	return src <= sourceFile
+}
+
+// satisfiesRequired reports whether a value from src fulfils required:"true".
+func (src valueSource) satisfiesRequired() bool {
+	return src <= sourceDefault
+}
+
+// isRequired reports whether tags make a field required, with either the
+// required or the stricter required_notempty tag.
+func isRequired(tags reflect.StructTag) bool {
+	return isTrue(tags.Get("required")) || isTrue(tags.Get("required_notempty"))
+}
+
+// checkRequired reports whether info, resolved to value from src, lacks a
+// value it requires, and if so whether that is because it was set to the
+// empty string, which only required_notempty rejects.
+func checkRequired(info varInfo, value string, src valueSource) (missing, empty bool) {
+	if !isRequired(info.Tags) {
+		return false, false
+	}
+	if !src.satisfiesRequired() {
+		return true, false
+	}
+	if src.fromEnvironment() && value == "" && isTrue(info.Tags.Get("required_notempty")) {
+		return true, true
+	}
+	return false, false
+}
+
+// resolveValue returns the value for info and where it came from, following
+// the precedence of valueSource.
+func resolveValue(info varInfo, options Options) (value string, src valueSource, err error) {
+	if value, src, ok := lookupValue(info, options); ok {
+		return value, src, nil
+	}
+
+	if options.FileFallback {
+		value, ok, err := lookupFile(info, options)
+		if err != nil || ok {
+			return value, sourceFile, err
+		}
+	}
+
+	if options.seeded[seedFieldOf(info.Field)] {
+		return "", sourceSeed, nil
+	}
+
+	switch def := info.Tags.Get("default"); {
+	case def == "" || def == zeroDefault:
+		return "", sourceZero, nil
+	case def == "now" && isTime(info.Field.Type()):
+		return def, sourceComputed, nil
+	default:
+		return def, sourceDefault, nil
+	}
+}
+
+// setZero resets the field behind info to its zero value if its default tag
+// asks for it.
+func setZero(info varInfo) {
+	if info.Tags.Get("default") == zeroDefault {
+		info.Field.Set(reflect.Zero(info.Field.Type()))
+	}
+}
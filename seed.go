@@ -0,0 +1,104 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ProcessWithJSONSeed populates the specified struct from a JSON document
+// held in the environment variable jsonEnvKey, then overrides individual
+// fields from their own environment variables as Process does. Only
+// variables that are actually set override seeded values; defaults apply to
+// fields the seed does not mention, so a seeded false or 0 is kept.
+func ProcessWithJSONSeed(prefix, jsonEnvKey string, spec interface{}) error {
+	return ProcessWithJSONSeedX(jsonEnvKey, spec, Options{Prefix: prefix})
+}
+
+// ProcessWithJSONSeedX is like ProcessWithJSONSeed but takes Options. The
+// seed is read through options.LookupFunc as well.
+func ProcessWithJSONSeedX(jsonEnvKey string, spec interface{}, options Options) error {
+	seeded := make(map[seedField]bool)
+	if seed, ok := options.lookup(jsonEnvKey); ok {
+		if err := json.Unmarshal([]byte(seed), spec); err != nil {
+			return fmt.Errorf("decoding JSON seed %s: %w", jsonEnvKey, err)
+		}
+		if s := reflect.ValueOf(spec); s.Kind() == reflect.Ptr && s.Elem().Kind() == reflect.Struct {
+			markSeeded([]byte(seed), s.Elem(), seeded)
+		}
+	}
+
+	options.seeded = seeded
+	return ProcessX(spec, options)
+}
+
+// seedField identifies a field by its address and type, since a struct and
+// its first field share an address.
+type seedField struct {
+	addr uintptr
+	typ  reflect.Type
+}
+
+func seedFieldOf(f reflect.Value) seedField {
+	return seedField{f.Addr().Pointer(), f.Type()}
+}
+
+// markSeeded records in seeded the fields of v, a struct, that the JSON
+// object data sets to something other than null, matching names the way
+// encoding/json does. Nested objects are followed into struct fields.
+func markSeeded(data []byte, v reflect.Value, seeded map[seedField]bool) {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) != nil {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		f, ftype := v.Field(i), t.Field(i)
+		tag := ftype.Tag.Get("json")
+		if tag == "-" || !f.CanSet() {
+			continue
+		}
+		for f.Kind() == reflect.Ptr && !f.IsNil() {
+			f = f.Elem()
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if ftype.Anonymous && name == "" && f.Kind() == reflect.Struct {
+			// embedded struct fields are promoted into the parent object
+			markSeeded(data, f, seeded)
+			continue
+		}
+		if name == "" {
+			name = ftype.Name
+		}
+		raw, ok := seedValue(object, name)
+		if !ok {
+			continue
+		}
+		seeded[seedFieldOf(f)] = true
+		if f.Kind() == reflect.Struct {
+			markSeeded(raw, f, seeded)
+		}
+	}
+}
+
+// seedValue returns the member of object named name, preferring an exact
+// match over a case-insensitive one, and reports whether it is set.
+func seedValue(object map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	raw, ok := object[name]
+	if !ok {
+		for key, value := range object {
+			if strings.EqualFold(key, name) {
+				raw, ok = value, true
+				break
+			}
+		}
+	}
+	return raw, ok && string(raw) != "null"
+}
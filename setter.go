@@ -0,0 +1,36 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+//nolint:gochecknoglobals
+var setterMethodType = reflect.TypeOf(func(string) error { return nil })
+
+// setterMethod looks up the method called name on the struct s, which must
+// be addressable, and checks that it has the signature func(string) error.
+func setterMethod(s reflect.Value, name string) (reflect.Value, error) {
+	m := s.Addr().MethodByName(name)
+	if !m.IsValid() {
+		return reflect.Value{}, fmt.Errorf("setter method %s not found on %s", name, s.Type())
+	}
+	if m.Type() != setterMethodType {
+		return reflect.Value{}, fmt.Errorf("setter method %s.%s must have signature func(string) error, got %s", s.Type(), name, m.Type())
+	}
+	return m, nil
+}
+
+// callSetter populates the field by invoking the method named in its setter
+// tag with the raw value.
+func (info varInfo) callSetter(value string, _ reflect.Value, _ reflect.StructTag, _ Options) error {
+	out := info.Setter.Call([]reflect.Value{reflect.ValueOf(value)})
+	if err, _ := out[0].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
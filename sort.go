@@ -0,0 +1,102 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// checkSortTag validates the sort tag of a struct field: it must be "asc" or
+// "desc" and is only allowed on slices of strings and numbers.
+func checkSortTag(ftype reflect.StructField) error {
+	order := ftype.Tag.Get("sort")
+	if order == "" {
+		return nil
+	}
+	if order != "asc" && order != "desc" {
+		return fmt.Errorf("field %s: invalid sort order %q", ftype.Name, order)
+	}
+
+	t := ftype.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice || !isOrdered(t.Elem()) {
+		return fmt.Errorf("field %s: sort requires a slice of strings or numbers, got %s", ftype.Name, ftype.Type)
+	}
+	return nil
+}
+
+// checkDedupTag validates the dedup tag of a struct field, which is allowed
+// on the same slices as sort.
+func checkDedupTag(ftype reflect.StructField) error {
+	if !isTrue(ftype.Tag.Get("dedup")) {
+		return nil
+	}
+	t := ftype.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Slice || !isOrdered(t.Elem()) {
+		return fmt.Errorf("field %s: dedup requires a slice of strings or numbers, got %s", ftype.Name, ftype.Type)
+	}
+	return nil
+}
+
+func isOrdered(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// sortSlice sorts sl in place in the given order. It does nothing when order
+// is empty or the elements are not ordered.
+func sortSlice(sl reflect.Value, order string) {
+	if order == "" || !isOrdered(sl.Type().Elem()) {
+		return
+	}
+
+	less := func(i, j int) bool {
+		a, b := sl.Index(i), sl.Index(j)
+		switch a.Kind() {
+		case reflect.String:
+			return a.String() < b.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return a.Uint() < b.Uint()
+		default:
+			return a.Float() < b.Float()
+		}
+	}
+	if order == "desc" {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+
+	sort.SliceStable(sl.Interface(), less)
+}
+
+// dedupSlice returns sl without repeated elements, keeping the first of each,
+// so a sorted slice stays sorted.
+func dedupSlice(sl reflect.Value) reflect.Value {
+	seen := make(map[interface{}]bool, sl.Len())
+	out := reflect.MakeSlice(sl.Type(), 0, sl.Len())
+	for i := 0; i < sl.Len(); i++ {
+		v := sl.Index(i)
+		if !seen[v.Interface()] {
+			seen[v.Interface()] = true
+			out = reflect.Append(out, v)
+		}
+	}
+	return out
+}
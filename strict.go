@@ -0,0 +1,57 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// checkStrict returns an error naming every variable in the environment that
+// starts with the prefix but that no field in infos would read.
+func checkStrict(spec interface{}, infos []varInfo, options Options) error {
+	options, err := applyDirective(reflect.TypeOf(spec).Elem(), options)
+	if err != nil || options.Prefix == "" {
+		return err
+	}
+	prefix := strings.ToUpper(options.Prefix) + "_"
+
+	var unknown []string
+	for _, env := range os.Environ() {
+		key := strings.SplitN(env, "=", 2)[0]
+		if strings.HasPrefix(key, prefix) && !knownKey(key, infos, options) {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown environment variables: %s", strings.Join(unknown, ", "))
+}
+
+// knownKey reports whether key is one of the variables read for infos,
+// including mapped keys, _FILE variants, indexed elements and negate keys.
+func knownKey(key string, infos []varInfo, options Options) bool {
+	for _, info := range infos {
+		for _, k := range lookupKeys(info, options) {
+			if key == k || options.FileFallback && key == k+"_FILE" {
+				return true
+			}
+		}
+		if key == strings.ToUpper(info.Tags.Get("negate")) {
+			return true
+		}
+		base := options.mapKey(info.Key) + "_"
+		if info.Tags.Get("indexed") != "" && strings.HasPrefix(key, base) &&
+			isDigits(strings.TrimPrefix(key, base)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+//nolint:gochecknoglobals
+var timeType = reflect.TypeOf(time.Time{})
+
+// isTime reports whether t is time.Time or a pointer to it.
+func isTime(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t == timeType
+}
+
+// checkTimeTags verifies that the layout and location tags are only used on
+// time.Time fields, or lists of them, and that the location is known.
+func checkTimeTags(ftype reflect.StructField) error {
+	layout, location := ftype.Tag.Get("layout"), ftype.Tag.Get("location")
+	if layout == "" && location == "" {
+		return nil
+	}
+	t := ftype.Type
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	if !isTime(t) {
+		return fmt.Errorf("field %s: layout and location require a time.Time field, got %s", ftype.Name, ftype.Type)
+	}
+	if _, err := time.LoadLocation(location); err != nil {
+		return fmt.Errorf("field %s: invalid location %q: %w", ftype.Name, location, err)
+	}
+	return nil
+}
+
+// processTime parses value into a time.Time field according to the format
+// tag, or else the layout and location tags. The only supported format is
+// "unix", seconds since the epoch. The layout defaults to RFC 3339, and the
+// location, an IANA zone name, to UTC.
+func processTime(value string, field reflect.Value, tags reflect.StructTag) error {
+	var t time.Time
+	switch format := tags.Get("format"); format {
+	case "unix":
+		sec, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		t = time.Unix(sec, 0).UTC()
+	case "":
+		layout := tags.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		loc, err := time.LoadLocation(tags.Get("location"))
+		if err != nil {
+			return err
+		}
+		if t, err = time.ParseInLocation(layout, value, loc); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown time format %q", format)
+	}
+
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(timeType))
+		}
+		field = field.Elem()
+	}
+	field.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// setNow sets a time.Time field to the current time, for default:"now".
+func setNow(_ string, field reflect.Value, _ reflect.StructTag, options Options) error {
+	if field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			field.Set(reflect.New(timeType))
+		}
+		field = field.Elem()
+	}
+	field.Set(reflect.ValueOf(options.now()))
+	return nil
+}
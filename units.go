@@ -0,0 +1,113 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// byteSizeUnits are the units of fields tagged bytesize:"true", in the form
+// of a units tag. Values are lowercased before they are matched against it.
+const byteSizeUnits = "b=1,kb=1000,mb=1000000,gb=1000000000,tb=1000000000000," +
+	"kib=1024,mib=1048576,gib=1073741824,tib=1099511627776"
+
+// unitsFor returns the units tag that applies to an integer field, and value
+// adjusted to be matched against it.
+func unitsFor(value string, tags reflect.StructTag) (string, string) {
+	if isTrue(tags.Get("bytesize")) {
+		return byteSizeUnits, strings.ToLower(value)
+	}
+	return tags.Get("units"), value
+}
+
+// parseUnits parses a units tag of the form "k=1000,m=1000000" into a map
+// from suffix to multiplier.
+func parseUnits(tag string) (map[string]uint64, error) {
+	units := make(map[string]uint64)
+	for _, item := range strings.Split(tag, ",") {
+		kv := strings.SplitN(item, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid unit %q", item)
+		}
+		mult, err := strconv.ParseUint(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid multiplier for unit %q: %w", kv[0], err)
+		}
+		units[strings.TrimSpace(kv[0])] = mult
+	}
+	return units, nil
+}
+
+// splitUnit separates value into a number and the multiplier of its unit
+// suffix. A value whose trailing letters are not a declared unit is returned
+// unchanged with a multiplier of 1, and only reported as an unknown unit if
+// it does not parse as a plain number either.
+func splitUnit(value, tag string, parse func(string) error) (string, uint64, error) {
+	units, err := parseUnits(tag)
+	if err != nil {
+		return "", 0, err
+	}
+
+	number := strings.TrimRightFunc(value, unicode.IsLetter)
+	suffix := value[len(number):]
+	if suffix == "" {
+		return value, 1, nil
+	}
+	if mult, ok := units[suffix]; ok {
+		return strings.TrimSpace(number), mult, nil
+	}
+	if parse(value) == nil {
+		return value, 1, nil
+	}
+	return "", 0, fmt.Errorf("unknown unit %q", suffix)
+}
+
+// parseIntUnits parses a signed integer with an optional unit suffix.
+func parseIntUnits(value, tag string, bits int) (int64, error) {
+	number, mult, err := splitUnit(value, tag, func(v string) error {
+		_, err := strconv.ParseInt(v, 0, bits)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseInt(number, 0, bits)
+	if err != nil {
+		return 0, err
+	}
+	if mult > math.MaxInt64 {
+		return 0, fmt.Errorf("value %s out of range", value)
+	}
+	val := n * int64(mult)
+	if n != 0 && (val/n != int64(mult) || bits < 64 && (val < -1<<uint(bits-1) || val >= 1<<uint(bits-1))) {
+		return 0, fmt.Errorf("value %s out of range", value)
+	}
+	return val, nil
+}
+
+// parseUintUnits parses an unsigned integer with an optional unit suffix.
+func parseUintUnits(value, tag string, bits int) (uint64, error) {
+	number, mult, err := splitUnit(value, tag, func(v string) error {
+		_, err := strconv.ParseUint(v, 0, bits)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(number, 0, bits)
+	if err != nil {
+		return 0, err
+	}
+	val := n * mult
+	if n != 0 && (val/n != mult || bits < 64 && val >= 1<<uint(bits)) {
+		return 0, fmt.Errorf("value %s out of range", value)
+	}
+	return val, nil
+}
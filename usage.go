@@ -6,10 +6,13 @@ package envconfig
 
 import (
 	"encoding"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"text/tabwriter"
@@ -26,6 +29,17 @@ variables can be used:
   [type]        {{usage_type .}}
   [default]     {{usage_default .}}
   [required]    {{usage_required .}}{{end}}
+`
+	// DefaultExtendedListFormat constant to use to display usage in a list
+	// format that also names the alternate keys of each variable
+	DefaultExtendedListFormat = `This application is configured via the environment. The following environment
+variables can be used:
+{{range .}}
+{{usage_key .}}{{with usage_altkeys .}} (also accepts: {{.}}){{end}}
+  [description] {{usage_description .}}
+  [type]        {{usage_type .}}
+  [default]     {{usage_default .}}
+  [required]    {{usage_required .}}{{end}}
 `
 	// DefaultTableFormat constant to use to display usage in a tabular format
 	DefaultTableFormat = `This application is configured via the environment. The following environment
@@ -33,6 +47,21 @@ variables can be used:
 
 KEY	TYPE	DEFAULT	REQUIRED	DESCRIPTION
 {{range .}}{{usage_key .}}	{{usage_type .}}	{{usage_default .}}	{{usage_required .}}	{{usage_description .}}
+{{end}}`
+	// DefaultExtendedTableFormat constant to use to display usage in a
+	// tabular format that also lists the validation constraints of each
+	// variable
+	DefaultExtendedTableFormat = `This application is configured via the environment. The following environment
+variables can be used:
+
+KEY	TYPE	DEFAULT	REQUIRED	CONSTRAINTS	DESCRIPTION
+{{range .}}{{usage_key .}}	{{usage_type .}}	{{usage_default .}}	{{usage_required .}}	{{usage_constraints .}}	{{usage_description .}}
+{{end}}`
+	// DefaultMarkdownFormat constant to use to display usage as a GitHub
+	// flavored Markdown table, for example in a README
+	DefaultMarkdownFormat = `| KEY | TYPE | DEFAULT | REQUIRED | DESCRIPTION |
+|---|---|---|---|---|
+{{range .}}| {{usage_key . | usage_markdown}} | {{usage_type . | usage_markdown}} | {{usage_default . | usage_markdown}} | {{usage_required .}} | {{usage_description . | usage_markdown}} |
 {{end}}`
 )
 
@@ -50,8 +79,30 @@ type UsageOptions struct {
 	Out        io.Writer
 	Format     string
 	Template   *template.Template
+
+	// Filter, when set, limits the output to the variables it accepts.
+	Filter func(VarInfo) bool
+
+	// Sort sets the order variables are listed in, which defaults to the
+	// order of declaration.
+	Sort UsageSort
+
+	// TagName is the struct tag that overrides the key of a field, as
+	// Options.TagName is for Process.
+	TagName string
 }
 
+// UsageSort is an order in which usage output lists variables.
+type UsageSort int
+
+// The orders usage output supports. Both sorts are stable, so required
+// variables keep their declaration order among themselves.
+const (
+	UsageSortDeclaration UsageSort = iota
+	UsageSortAlphabetical
+	UsageSortRequiredFirst
+)
+
 func implementsInterface(t reflect.Type) bool {
 	return t.Implements(decoderType) ||
 		reflect.PtrTo(t).Implements(decoderType) ||
@@ -65,17 +116,63 @@ func implementsInterface(t reflect.Type) bool {
 
 // toTypeDescription converts Go types into a human readable description
 func toTypeDescription(t reflect.Type) string {
+	return typeDescription(t, "")
+}
+
+// typeDescription is like toTypeDescription but describes lists as separated
+// by the delimiter tag in tags.
+func typeDescription(t reflect.Type, tags reflect.StructTag) string {
+	if t == urlValuesType {
+		return "URL-encoded key/value pairs"
+	}
+	if t == semVerType {
+		return "Semantic Version"
+	}
+	if isTrue(tags.Get("bytesize")) && isInteger(t) {
+		return "Byte size (e.g. 256MB)"
+	}
+	switch t {
+	case ipType:
+		return "IP Address"
+	case ipNetType:
+		return "CIDR"
+	case hardwareAddrType:
+		return "MAC Address"
+	}
+
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map:
+		if implementsInterface(t) && t.Name() != "" {
+			return t.Name()
+		}
+	}
+
+	if isBytes(t) && t.Kind() != reflect.Ptr {
+		switch bytesEncoding(tags) {
+		case "raw":
+			return "Bytes"
+		case "hex":
+			return "Hex-encoded bytes"
+		case "base64url":
+			return "Base64url-encoded bytes"
+		default:
+			return "Base64-encoded bytes"
+		}
+	}
+
 	switch t.Kind() {
 	case reflect.Array, reflect.Slice:
-		return fmt.Sprintf("Comma-separated list of %s", toTypeDescription(t.Elem()))
+		return fmt.Sprintf("%s list of %s", delimiterName(tags), toTypeDescription(t.Elem()))
 	case reflect.Map:
 		return fmt.Sprintf(
-			"Comma-separated list of %s:%s pairs",
+			"%s list of %s%s%s pairs",
+			delimiterName(tags),
 			toTypeDescription(t.Key()),
+			mapSeparator(tags),
 			toTypeDescription(t.Elem()),
 		)
 	case reflect.Ptr:
-		return toTypeDescription(t.Elem())
+		return typeDescription(t.Elem(), tags)
 	case reflect.Struct:
 		if implementsInterface(t) && t.Name() != "" {
 			return t.Name()
@@ -92,7 +189,7 @@ func toTypeDescription(t reflect.Type) string {
 		if name != "" && name != "bool" {
 			return name
 		}
-		return "True or False"
+		return "True or False (yes/no, on/off)"
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		name := t.Name()
 		if name != "" && !strings.HasPrefix(name, "int") {
@@ -115,6 +212,83 @@ func toTypeDescription(t reflect.Type) string {
 	return fmt.Sprintf("%+v", t)
 }
 
+// toFieldDescription describes the field behind v, listing the allowed
+// values of an Enum or a Switch in place of its type name.
+func toFieldDescription(v varInfo) string {
+	switch f := v.Field.Interface().(type) {
+	case Enum:
+		return fmt.Sprintf("One of %s", strings.Join(f.Values(), ", "))
+	case Switch:
+		return "One of on, off, auto"
+	}
+	return typeDescription(v.Field.Type(), v.Tags)
+}
+
+// delimiterName describes the list delimiter set in tags, as in
+// "Comma-separated".
+func delimiterName(tags reflect.StructTag) string {
+	sep := listDelimiter(tags)
+	names := map[string]string{
+		",":  "Comma",
+		";":  "Semicolon",
+		":":  "Colon",
+		"|":  "Pipe",
+		" ":  "Space",
+		"\t": "Tab",
+		"\n": "Newline",
+	}
+	if name, ok := names[sep]; ok {
+		return name + "-separated"
+	}
+	return fmt.Sprintf("%q-separated", sep)
+}
+
+func usageKey(v varInfo) string { return v.Key }
+
+func usageDescription(v varInfo) string { return v.Tags.Get("desc") }
+
+func usageAltKeys(v varInfo) string { return strings.Join(lookupKeys(v, Options{})[1:], ", ") }
+
+func usageConstraints(v varInfo) string {
+	return strings.Join(describeConstraints(v.Tags), "; ")
+}
+
+func usageDefault(v varInfo) string {
+	if def := v.Tags.Get("default"); def != "" && v.Sensitive {
+		return sensitiveMask
+	}
+	return v.Tags.Get("default")
+}
+
+func usageMin(v varInfo) string { return v.Tags.Get("min") }
+
+func usageMax(v varInfo) string { return v.Tags.Get("max") }
+
+func usageOneOf(v varInfo) string { return strings.Join(strings.Fields(v.Tags.Get("oneof")), ", ") }
+
+// usageMarkdown escapes s for a cell of a Markdown table.
+func usageMarkdown(s string) string {
+	s = strings.Replace(s, "|", `\|`, -1)
+	return strings.Replace(s, "\n", " ", -1)
+}
+
+func usageRequired(v varInfo) (string, error) {
+	req := v.Tags.Get("required")
+	if req == "" && isTrue(v.Tags.Get("required_notempty")) {
+		req = "true"
+	}
+	if req != "" {
+		reqB, err := strconv.ParseBool(req)
+		if err != nil {
+			return "", err
+		}
+		if reqB {
+			req = "true"
+		}
+	}
+	return req, nil
+}
+
 // Usage writes usage information to stderr using the default header and table format
 func Usage(prefix string, spec interface{}) error {
 	return UsageX(spec, Options{Prefix: prefix})
@@ -152,6 +326,7 @@ func UsageX(spec interface{}, options Options) error {
 		SplitWords: options.SplitWords,
 		Out:        tabs,
 		Format:     DefaultTableFormat,
+		TagName:    options.TagName,
 	}
 
 	err := UsagefX(spec, usageOptions)
@@ -162,23 +337,17 @@ func UsageX(spec interface{}, options Options) error {
 func UsagefX(spec interface{}, usageOptions UsageOptions) error {
 	// Specify the default usage template functions
 	functions := template.FuncMap{
-		"usage_key":         func(v varInfo) string { return v.Key },
-		"usage_description": func(v varInfo) string { return v.Tags.Get("desc") },
-		"usage_type":        func(v varInfo) string { return toTypeDescription(v.Field.Type()) },
-		"usage_default":     func(v varInfo) string { return v.Tags.Get("default") },
-		"usage_required": func(v varInfo) (string, error) {
-			req := v.Tags.Get("required")
-			if req != "" {
-				reqB, err := strconv.ParseBool(req)
-				if err != nil {
-					return "", err
-				}
-				if reqB {
-					req = "true"
-				}
-			}
-			return req, nil
-		},
+		"usage_key":         usageKey,
+		"usage_description": usageDescription,
+		"usage_type":        toFieldDescription,
+		"usage_default":     usageDefault,
+		"usage_required":    usageRequired,
+		"usage_altkeys":     usageAltKeys,
+		"usage_constraints": usageConstraints,
+		"usage_min":         usageMin,
+		"usage_max":         usageMax,
+		"usage_oneof":       usageOneOf,
+		"usage_markdown":    usageMarkdown,
 	}
 
 	if usageOptions.Template == nil {
@@ -194,15 +363,143 @@ func UsagefX(spec interface{}, usageOptions UsageOptions) error {
 }
 
 func UsagetX(spec interface{}, usageOptions UsageOptions) error {
+	infos, err := usageInfos(spec, usageOptions)
+	if err != nil {
+		return err
+	}
+
+	return usageOptions.Template.Execute(usageOptions.Out, infos)
+}
+
+// usageInfos gathers the variables of spec to list in usage output, filtered
+// and sorted as usageOptions asks.
+func usageInfos(spec interface{}, usageOptions UsageOptions) ([]varInfo, error) {
 	options := Options{
 		Prefix:     usageOptions.Prefix,
 		SplitWords: usageOptions.SplitWords,
+		TagName:    usageOptions.TagName,
 	}
 
 	infos, err := gatherInfo(spec, options)
+	if err != nil {
+		return nil, err
+	}
+
+	if usageOptions.Filter != nil {
+		filtered := infos[:0]
+		for _, info := range infos {
+			if usageOptions.Filter(info.export(options)) {
+				filtered = append(filtered, info)
+			}
+		}
+		infos = filtered
+	}
+
+	switch usageOptions.Sort {
+	case UsageSortAlphabetical:
+		sort.SliceStable(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	case UsageSortRequiredFirst:
+		sort.SliceStable(infos, func(i, j int) bool { return isRequired(infos[i].Tags) && !isRequired(infos[j].Tags) })
+	}
+	return infos, nil
+}
+
+// UsageFiltered writes usage information for the variables accepted by pred
+// to out, in the default table format.
+func UsageFiltered(prefix string, spec interface{}, out io.Writer, pred func(VarInfo) bool) error {
+	tabs := tabwriter.NewWriter(out, 1, 0, 4, ' ', 0)
+
+	err := UsagefX(spec, UsageOptions{
+		Prefix: prefix,
+		Out:    tabs,
+		Format: DefaultTableFormat,
+		Filter: pred,
+	})
 	if err != nil {
 		return err
 	}
+	return tabs.Flush()
+}
 
-	return usageOptions.Template.Execute(usageOptions.Out, infos)
+// usageEntry is the JSON form of a variable in UsageJSON output.
+type usageEntry struct {
+	Key         string `json:"key"`
+	AltKey      string `json:"alt_key,omitempty"`
+	Type        string `json:"type"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+	Description string `json:"description,omitempty"`
+}
+
+// UsageJSON writes usage information to the specified io.Writer as a JSON
+// array with one object per variable.
+func UsageJSON(prefix string, spec interface{}, out io.Writer) error {
+	return UsageJSONX(spec, UsageOptions{Prefix: prefix, Out: out})
+}
+
+// UsageJSONX is like UsageJSON but takes UsageOptions. Format and Template
+// are ignored.
+func UsageJSONX(spec interface{}, usageOptions UsageOptions) error {
+	infos, err := usageInfos(spec, usageOptions)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]usageEntry, 0, len(infos))
+	for _, info := range infos {
+		req, err := usageRequired(info)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, usageEntry{
+			Key:         usageKey(info),
+			AltKey:      usageAltKeys(info),
+			Type:        toFieldDescription(info),
+			Default:     usageDefault(info),
+			Required:    req == "true",
+			Description: usageDescription(info),
+		})
+	}
+
+	enc := json.NewEncoder(usageOptions.Out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// UsageCSV writes usage information to the specified io.Writer as CSV, with a
+// header row followed by one row per variable.
+func UsageCSV(prefix string, spec interface{}, out io.Writer) error {
+	return UsageCSVX(spec, UsageOptions{Prefix: prefix, Out: out})
+}
+
+// UsageCSVX is like UsageCSV but takes UsageOptions. Format and Template
+// are ignored.
+func UsageCSVX(spec interface{}, usageOptions UsageOptions) error {
+	infos, err := usageInfos(spec, usageOptions)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(usageOptions.Out)
+	if err := w.Write([]string{"KEY", "TYPE", "DEFAULT", "REQUIRED", "DESCRIPTION"}); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		req, err := usageRequired(info)
+		if err != nil {
+			return err
+		}
+		record := []string{
+			usageKey(info),
+			toFieldDescription(info),
+			usageDefault(info),
+			req,
+			usageDescription(info),
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
 }
@@ -6,13 +6,17 @@ package envconfig
 
 import (
 	"bytes"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"log"
+	"net"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"text/tabwriter"
+	"time"
 )
 
 //nolint:gochecknoglobals
@@ -197,3 +201,307 @@ func TestUsageX(t *testing.T) {
 
 	compareUsage(testUsageX, out, t)
 }
+
+func TestUsageCSV(t *testing.T) {
+	var s struct {
+		Hosts []string `default:"a,b" desc:"hosts, in order"`
+		Port  int      `required:"true"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	if err := UsageCSV("env_config", &s, buf); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	const want = `KEY,TYPE,DEFAULT,REQUIRED,DESCRIPTION
+ENV_CONFIG_HOSTS,Comma-separated list of String,"a,b",,"hosts, in order"
+ENV_CONFIG_PORT,Integer,,true,
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestUsageCSVX(t *testing.T) {
+	var s struct {
+		Port     int    `required:"true"`
+		Host     string `default:"localhost"`
+		Internal string `ignored:"true"`
+		Debug    bool
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	usageOptions := UsageOptions{
+		Prefix: "env_config",
+		Out:    buf,
+		Filter: func(v VarInfo) bool { return v.Key != "ENV_CONFIG_DEBUG" },
+		Sort:   UsageSortAlphabetical,
+	}
+	if err := UsageCSVX(&s, usageOptions); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	const want = `KEY,TYPE,DEFAULT,REQUIRED,DESCRIPTION
+ENV_CONFIG_HOST,String,localhost,,
+ENV_CONFIG_PORT,Integer,,true,
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestUsageAltKeys(t *testing.T) {
+	var s struct {
+		Host string `envconfig:"SERVICE_HOST"`
+		Port int
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, DefaultExtendedListFormat); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if want := "ENV_CONFIG_SERVICE_HOST (also accepts: SERVICE_HOST)\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected usage to contain %q, got:\n%s", want, buf.String())
+	}
+	if want := "ENV_CONFIG_PORT\n"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected usage to contain %q, got:\n%s", want, buf.String())
+	}
+}
+
+func TestUsageConstraints(t *testing.T) {
+	var s struct {
+		Port    int           `validate:"min=1,max=65535"`
+		Level   string        `enum:"debug,info"`
+		Timeout time.Duration `validate:"positive,max=30s"`
+		Size    int           `validate:"multipleof=4096"`
+		Workers int           `min:"1" max:"64"`
+		Retries int           `min:"0"`
+		Mode    string        `oneof:"r w"`
+		Code    string        `pattern:"^[A-Z]{3}$"`
+		Name    string
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}}={{usage_constraints .}}\n{{end}}")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	const want = `ENV_CONFIG_PORT=1–65535
+ENV_CONFIG_LEVEL=one of: debug,info
+ENV_CONFIG_TIMEOUT=> 0; <= 30s
+ENV_CONFIG_SIZE=multiple of 4096
+ENV_CONFIG_WORKERS=1–64
+ENV_CONFIG_RETRIES=>= 0
+ENV_CONFIG_MODE=one of: r,w
+ENV_CONFIG_CODE=matches ^[A-Z]{3}$
+ENV_CONFIG_NAME=
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	tabs := tabwriter.NewWriter(buf, 1, 0, 4, ' ', 0)
+	if err := Usagef("env_config", &s, tabs, DefaultExtendedTableFormat); err != nil {
+		t.Fatal(err.Error())
+	}
+	tabs.Flush()
+	if !strings.Contains(buf.String(), "CONSTRAINTS") {
+		t.Errorf("expected a constraints column, got:\n%s", buf.String())
+	}
+}
+
+func TestUsageDelimiter(t *testing.T) {
+	var s struct {
+		Paths []string          `delimiter:";"`
+		Ports [2]int            `delimiter:"|"`
+		Hosts map[string]int    `delimiter:"#"`
+		URLs  map[string]string `delimiter:";" separator:"="`
+		Bind  net.IP
+		Peers []net.IPNet
+		MAC   *net.HardwareAddr
+		Limit int64    `bytesize:"true"`
+		Key   []byte   `encoding:"base64"`
+		Sum   [32]byte `encoding:"hex"`
+		Raw   *[]byte
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_type .}}\n{{end}}"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	const want = `Semicolon-separated list of String
+Pipe-separated list of Integer
+"#"-separated list of String:Integer pairs
+Semicolon-separated list of String=String pairs
+IP Address
+Comma-separated list of CIDR
+MAC Address
+Byte size (e.g. 256MB)
+Base64-encoded bytes
+Hex-encoded bytes
+Bytes
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestUsageFiltered(t *testing.T) {
+	var s struct {
+		LogLevel string `reload:"true" desc:"log verbosity"`
+		Port     int
+		Limits   struct {
+			Rate int `reload:"true"`
+		}
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	reloadable := func(v VarInfo) bool { return isTrue(v.Tags.Get("reload")) }
+	if err := UsageFiltered("env_config", &s, buf, reloadable); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	out := buf.String()
+	for _, want := range []string{"ENV_CONFIG_LOGLEVEL", "log verbosity", "ENV_CONFIG_LIMITS_RATE"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected usage to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "ENV_CONFIG_PORT") {
+		t.Errorf("expected ENV_CONFIG_PORT to be filtered out, got:\n%s", out)
+	}
+}
+
+func TestUsageMinMax(t *testing.T) {
+	var s struct {
+		Port int `min:"1" max:"65535"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, "{{range .}}{{usage_key .}} {{usage_min .}}..{{usage_max .}}{{end}}"); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "ENV_CONFIG_PORT 1..65535"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+}
+
+func TestUsageSensitive(t *testing.T) {
+	var s struct {
+		Password string `default:"hunter2" sensitive:"true"`
+		User     string `default:"admin"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	format := "{{range .}}{{usage_key .}}={{usage_default .}}{{if .Sensitive}} (sensitive){{end}}\n{{end}}"
+	if err := Usagef("env_config", &s, buf, format); err != nil {
+		t.Fatal(err.Error())
+	}
+	if want := "ENV_CONFIG_PASSWORD=**** (sensitive)\nENV_CONFIG_USER=admin\n"; buf.String() != want {
+		t.Errorf("expected %q, got %q", want, buf.String())
+	}
+
+	if err := Process("env_config", &s); err != nil {
+		t.Fatal(err.Error())
+	}
+	if s.Password != "hunter2" {
+		t.Errorf("expected processing to be unaffected, got %q", s.Password)
+	}
+
+	vars, err := Marshal("env_config", &s)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	if vars[0].Value != "****" || vars[1].Value != "admin" {
+		t.Errorf("expected sensitive value to be masked, got %v", vars)
+	}
+}
+
+func TestUsageJSON(t *testing.T) {
+	var s struct {
+		ServiceHost string   `envconfig:"SERVICE_HOST" required:"true" desc:"upstream host"`
+		MaxRetries  int      `default:"3"`
+		Hosts       []string `split_words:"true"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	if err := UsageJSONX(&s, UsageOptions{Prefix: "env_config", SplitWords: true, Out: buf}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var got []map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err.Error())
+	}
+	expected := []map[string]interface{}{
+		{"key": "ENV_CONFIG_SERVICE_HOST", "alt_key": "SERVICE_HOST", "type": "String", "required": true, "description": "upstream host"},
+		{"key": "ENV_CONFIG_MAX_RETRIES", "type": "Integer", "default": "3", "required": false},
+		{"key": "ENV_CONFIG_HOSTS", "type": "Comma-separated list of String", "required": false},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	buf.Reset()
+	if err := UsageJSON("env_config", &s, buf); err != nil {
+		t.Fatal(err.Error())
+	}
+	if !strings.Contains(buf.String(), `"key": "ENV_CONFIG_MAXRETRIES"`) {
+		t.Errorf("expected keys without split words, got %s", buf.String())
+	}
+}
+
+func TestUsageMarkdown(t *testing.T) {
+	var s struct {
+		Mode  string   `default:"a|b" desc:"pick a|b"`
+		Hosts []string `required:"true"`
+	}
+	os.Clearenv()
+	buf := new(bytes.Buffer)
+	if err := Usagef("env_config", &s, buf, DefaultMarkdownFormat); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	const want = `| KEY | TYPE | DEFAULT | REQUIRED | DESCRIPTION |
+|---|---|---|---|---|
+| ENV_CONFIG_MODE | String | a\|b |  | pick a\|b |
+| ENV_CONFIG_HOSTS | Comma-separated list of String |  | true |  |
+`
+	if buf.String() != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, buf.String())
+	}
+}
+
+func TestUsageSort(t *testing.T) {
+	var s struct {
+		Port     int
+		Host     string `required:"true"`
+		Debug    bool
+		Password string `required_notempty:"true"`
+	}
+	os.Clearenv()
+
+	for sortBy, want := range map[UsageSort]string{
+		UsageSortDeclaration:   "ENV_CONFIG_PORT ENV_CONFIG_HOST ENV_CONFIG_DEBUG ENV_CONFIG_PASSWORD ",
+		UsageSortAlphabetical:  "ENV_CONFIG_DEBUG ENV_CONFIG_HOST ENV_CONFIG_PASSWORD ENV_CONFIG_PORT ",
+		UsageSortRequiredFirst: "ENV_CONFIG_HOST ENV_CONFIG_PASSWORD ENV_CONFIG_PORT ENV_CONFIG_DEBUG ",
+	} {
+		buf := new(bytes.Buffer)
+		err := UsagefX(&s, UsageOptions{
+			Prefix: "env_config",
+			Out:    buf,
+			Format: "{{range .}}{{usage_key .}} {{end}}",
+			Sort:   sortBy,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		if buf.String() != want {
+			t.Errorf("sort %d: expected %q, got %q", sortBy, want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,532 @@
+// Copyright (c) 2013 Kelsey Hightower. All rights reserved.
+// Use of this source code is governed by the MIT License that can be found in
+// the LICENSE file.
+
+package envconfig
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A ValidationError occurs when a value was converted successfully but
+// violates a rule declared in the validate tag of its struct field.
+type ValidationError struct {
+	KeyName   string
+	FieldName string
+	Rule      string
+	Err       error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf(
+		"envconfig.Process: validating %[1]s for %[2]s: rule %[3]s: %[4]s",
+		e.KeyName, e.FieldName, e.Rule, e.Err,
+	)
+}
+
+// validateRules returns the rules listed in the validate tag. Each rule is
+// either a bare name or a name=argument pair.
+func validateRules(tags reflect.StructTag) []string {
+	tag := tags.Get("validate")
+	if tag == "" {
+		return nil
+	}
+	rules := strings.Split(tag, ",")
+	for i := range rules {
+		rules[i] = strings.TrimSpace(rules[i])
+	}
+	return rules
+}
+
+// splitRule splits a rule into its name and argument.
+func splitRule(rule string) (name, arg string) {
+	kv := strings.SplitN(rule, "=", 2)
+	if len(kv) == 2 {
+		return kv[0], kv[1]
+	}
+	return kv[0], ""
+}
+
+// checkValidateTag verifies that every rule in the validate tag of a struct
+// field is known and applicable to the field's type.
+func checkValidateTag(ftype reflect.StructField) error {
+	elem := elemType(ftype.Type)
+	for _, rule := range validateRules(ftype.Tag) {
+		name, arg := splitRule(rule)
+		switch name {
+		case "positive", "nonnegative":
+			if !isNumeric(elem) {
+				return fmt.Errorf("field %s: rule %s requires a numeric or duration field, got %s", ftype.Name, rule, ftype.Type)
+			}
+		case "min", "max":
+			if !isNumeric(elem) {
+				return fmt.Errorf("field %s: rule %s requires a numeric or duration field, got %s", ftype.Name, rule, ftype.Type)
+			}
+			if _, err := parseBound(arg, elem); err != nil {
+				return fmt.Errorf("field %s: rule %s: invalid bound: %w", ftype.Name, rule, err)
+			}
+		case "future", "past":
+			if elem != timeType {
+				return fmt.Errorf("field %s: rule %s requires a time.Time field, got %s", ftype.Name, rule, ftype.Type)
+			}
+		case "multipleof":
+			if !isInteger(elem) {
+				return fmt.Errorf("field %s: rule %s requires an integer field, got %s", ftype.Name, rule, ftype.Type)
+			}
+			bound, err := parseBound(arg, elem)
+			if err != nil {
+				return fmt.Errorf("field %s: rule %s: invalid multiple: %w", ftype.Name, rule, err)
+			}
+			if sign(bound) <= 0 {
+				return fmt.Errorf("field %s: rule %s: multiple must be positive", ftype.Name, rule)
+			}
+		default:
+			return fmt.Errorf("field %s: unknown validation rule %q", ftype.Name, rule)
+		}
+	}
+	if ftype.Tag.Get("enum") != "" && elem.Kind() != reflect.String {
+		return fmt.Errorf("field %s: enum requires a string field, got %s", ftype.Name, ftype.Type)
+	}
+	return nil
+}
+
+// checkRangeTags verifies that the min and max tags of a struct field are
+// on a numeric field and parse as values of its type.
+func checkRangeTags(ftype reflect.StructField) error {
+	elem := elemType(ftype.Type)
+	for _, name := range []string{"min", "max"} {
+		arg := ftype.Tag.Get(name)
+		if arg == "" {
+			continue
+		}
+		if !isNumeric(elem) {
+			return fmt.Errorf("field %s: %s requires a numeric field, got %s", ftype.Name, name, ftype.Type)
+		}
+		if _, err := parseBound(arg, elem); err != nil {
+			return fmt.Errorf("field %s: invalid %s bound %q: %w", ftype.Name, name, arg, err)
+		}
+	}
+	return nil
+}
+
+// checkRange verifies that the value of field, or each of its elements,
+// lies within the inclusive bounds of the min and max tags.
+func checkRange(field reflect.Value, tags reflect.StructTag) error {
+	for _, name := range []string{"min", "max"} {
+		if arg := tags.Get(name); arg != "" {
+			if err := eachValue(field, validateRule(name+"="+arg, Options{})); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkMaxBytesTag verifies that the maxbytes tag of a struct field is a
+// positive integer on a string, slice or map field.
+func checkMaxBytesTag(ftype reflect.StructField) error {
+	tag := ftype.Tag.Get("maxbytes")
+	if tag == "" {
+		return nil
+	}
+	t := ftype.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+	default:
+		return fmt.Errorf("field %s: maxbytes requires a string, slice or map field, got %s", ftype.Name, ftype.Type)
+	}
+	if n, err := strconv.Atoi(tag); err != nil || n <= 0 {
+		return fmt.Errorf("field %s: maxbytes must be a positive integer, got %q", ftype.Name, tag)
+	}
+	return nil
+}
+
+// checkMaxBytes verifies that the raw value is no longer than the maxbytes
+// tag allows, before it is split or parsed.
+func checkMaxBytes(value string, tags reflect.StructTag) error {
+	tag := tags.Get("maxbytes")
+	if tag == "" {
+		return nil
+	}
+	if max, _ := strconv.Atoi(tag); len(value) > max {
+		return fmt.Errorf("value is %d bytes, more than maxbytes %d", len(value), max)
+	}
+	return nil
+}
+
+// checkOneOfTag verifies that the oneof tag of a struct field is on a
+// string field.
+func checkOneOfTag(ftype reflect.StructField) error {
+	if ftype.Tag.Get("oneof") != "" && elemType(ftype.Type).Kind() != reflect.String {
+		return fmt.Errorf("field %s: oneof requires a string field, got %s", ftype.Name, ftype.Type)
+	}
+	return nil
+}
+
+// checkOneOf verifies that the value of field, or each of its elements, is
+// one of the space-separated values in the oneof tag. Matching ignores case
+// when the oneof_ci tag is true.
+func checkOneOf(field reflect.Value, tags reflect.StructTag) error {
+	allowed := strings.Fields(tags.Get("oneof"))
+	if len(allowed) == 0 {
+		return nil
+	}
+	return eachValue(field, enumRule(allowed, isTrue(tags.Get("oneof_ci"))))
+}
+
+// compilePattern compiles the pattern tag of a struct field, which is only
+// allowed on strings and slices of them. It returns nil when the tag is
+// absent.
+func compilePattern(ftype reflect.StructField) (*regexp.Regexp, error) {
+	pattern := ftype.Tag.Get("pattern")
+	if pattern == "" {
+		return nil, nil
+	}
+	if elemType(ftype.Type).Kind() != reflect.String {
+		return nil, fmt.Errorf("field %s: pattern requires a string field, got %s", ftype.Name, ftype.Type)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("field %s: invalid pattern: %w", ftype.Name, err)
+	}
+	return re, nil
+}
+
+// checkPattern verifies that the value of field, or each of its elements,
+// matches re.
+func checkPattern(field reflect.Value, re *regexp.Regexp) error {
+	if re == nil {
+		return nil
+	}
+	return eachValue(field, func(v reflect.Value) error {
+		if !re.MatchString(v.String()) {
+			return fmt.Errorf("%q does not match pattern %s", v.String(), re)
+		}
+		return nil
+	})
+}
+
+// maskRule wraps rule so that its errors hide the value they were given.
+func maskRule(rule func(reflect.Value) error) func(reflect.Value) error {
+	return func(v reflect.Value) error {
+		err := rule(v)
+		if s := fmt.Sprint(v.Interface()); err != nil && s != "" {
+			return maskedError{err: err, value: s}
+		}
+		return err
+	}
+}
+
+// validateField applies the rules in the validate tag of info to its parsed
+// value. Slices and maps are validated element by element. The values of a
+// sensitive field are masked in the errors.
+func validateField(info varInfo, options Options) error {
+	check := func(rule func(reflect.Value) error) error {
+		if info.Sensitive {
+			rule = maskRule(rule)
+		}
+		return eachValue(info.Field, rule)
+	}
+
+	if allowed := info.Tags.Get("enum"); allowed != "" {
+		if err := check(enumRule(strings.Split(allowed, ","), options.CaseInsensitiveValues)); err != nil {
+			return &ValidationError{
+				KeyName:   options.mapKey(info.Key),
+				FieldName: info.Name,
+				Rule:      "enum",
+				Err:       err,
+			}
+		}
+	}
+
+	for _, rule := range validateRules(info.Tags) {
+		if err := check(validateRule(rule, options)); err != nil {
+			return &ValidationError{
+				KeyName:   options.mapKey(info.Key),
+				FieldName: info.Name,
+				Rule:      rule,
+				Err:       err,
+			}
+		}
+	}
+	return nil
+}
+
+// describeConstraints summarizes the validation rules declared in tags in
+// human readable form, one entry per constraint. It covers the enum,
+// validate, min, max, oneof and pattern tags.
+func describeConstraints(tags reflect.StructTag) []string {
+	var constraints []string
+	if allowed := tags.Get("enum"); allowed != "" {
+		constraints = append(constraints, "one of: "+allowed)
+	}
+	if allowed := strings.Fields(tags.Get("oneof")); len(allowed) > 0 {
+		constraints = append(constraints, "one of: "+strings.Join(allowed, ","))
+	}
+
+	min, max := tags.Get("min"), tags.Get("max")
+	for _, rule := range validateRules(tags) {
+		name, arg := splitRule(rule)
+		switch name {
+		case "positive":
+			constraints = append(constraints, "> 0")
+		case "nonnegative":
+			constraints = append(constraints, ">= 0")
+		case "min":
+			min = arg
+		case "max":
+			max = arg
+		case "multipleof":
+			constraints = append(constraints, "multiple of "+arg)
+		case "future", "past":
+			constraints = append(constraints, "in the "+name)
+		}
+	}
+	switch {
+	case min != "" && max != "":
+		constraints = append(constraints, min+"–"+max)
+	case min != "":
+		constraints = append(constraints, ">= "+min)
+	case max != "":
+		constraints = append(constraints, "<= "+max)
+	}
+	if pattern := tags.Get("pattern"); pattern != "" {
+		constraints = append(constraints, "matches "+pattern)
+	}
+	return constraints
+}
+
+// eachValue calls fn for v, or for every element of v when it is a slice,
+// array or map. Errors are annotated with the offending index or key.
+func eachValue(v reflect.Value, fn func(reflect.Value) error) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := eachValue(v.Index(i), fn); err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+		}
+		return nil
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+		})
+		for _, k := range keys {
+			if err := eachValue(v.MapIndex(k), fn); err != nil {
+				return fmt.Errorf("key %v: %w", k.Interface(), err)
+			}
+		}
+		return nil
+	}
+
+	return fn(v)
+}
+
+// validateRule returns a check for a single rule from the validate tag.
+func validateRule(rule string, options Options) func(reflect.Value) error {
+	name, arg := splitRule(rule)
+	return func(v reflect.Value) error {
+		switch name {
+		case "future":
+			if t := v.Interface().(time.Time); !t.After(options.now()) {
+				return fmt.Errorf("time %s is not in the future", t.Format(time.RFC3339))
+			}
+		case "past":
+			if t := v.Interface().(time.Time); !t.Before(options.now()) {
+				return fmt.Errorf("time %s is not in the past", t.Format(time.RFC3339))
+			}
+		case "positive":
+			if sign(v) <= 0 {
+				return errors.New("value must be positive")
+			}
+		case "nonnegative":
+			if sign(v) < 0 {
+				return errors.New("value must not be negative")
+			}
+		case "min", "max":
+			bound, err := parseBound(arg, v.Type())
+			if err != nil {
+				return err
+			}
+			c := compareNumbers(v, bound)
+			if name == "min" && c < 0 {
+				return fmt.Errorf("value %v is less than %s", v.Interface(), arg)
+			}
+			if name == "max" && c > 0 {
+				return fmt.Errorf("value %v is greater than %s", v.Interface(), arg)
+			}
+		case "multipleof":
+			multiple, err := parseBound(arg, v.Type())
+			if err != nil {
+				return err
+			}
+			if !isMultiple(v, multiple) {
+				return fmt.Errorf("value %v is not a multiple of %s", v.Interface(), arg)
+			}
+		}
+		return nil
+	}
+}
+
+// enumRule returns a check that a string value is one of allowed. When
+// matching ignores case, settable values are canonicalized to the spelling in
+// allowed.
+func enumRule(allowed []string, ignoreCase bool) func(reflect.Value) error {
+	return func(v reflect.Value) error {
+		for _, a := range allowed {
+			if v.String() == a {
+				return nil
+			}
+			if ignoreCase && strings.EqualFold(v.String(), a) {
+				if v.CanSet() {
+					v.SetString(a)
+				}
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", v.String(), strings.Join(allowed, ", "))
+	}
+}
+
+// parseBound parses a rule argument into a value of type t, using the same
+// conversion as a field of that type.
+func parseBound(arg string, t reflect.Type) (reflect.Value, error) {
+	bound := reflect.New(t).Elem()
+	if err := processField(arg, bound, "", Options{}); err != nil {
+		return reflect.Value{}, err
+	}
+	return bound, nil
+}
+
+// elemType returns the element type of t, unwrapping pointers, slices,
+// arrays and maps.
+func elemType(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
+
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// isMultiple reports whether the integer v is a multiple of m, which must be
+// of the same kind and positive.
+func isMultiple(v, m reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint()%m.Uint() == 0
+	default:
+		return v.Int()%m.Int() == 0
+	}
+}
+
+func isNumeric(t reflect.Type) bool {
+	return isOrdered(t) && t.Kind() != reflect.String
+}
+
+// compareNumbers returns -1, 0 or 1 as a is less than, equal to or greater
+// than b. Both must be numbers of the same kind.
+func compareNumbers(a, b reflect.Value) int {
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compareInts(a.Int(), b.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		switch {
+		case a.Uint() < b.Uint():
+			return -1
+		case a.Uint() > b.Uint():
+			return 1
+		}
+	case reflect.Float32, reflect.Float64:
+		switch {
+		case a.Float() < b.Float():
+			return -1
+		case a.Float() > b.Float():
+			return 1
+		}
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// sign returns -1, 0 or 1 depending on the sign of the numeric value v.
+func sign(v reflect.Value) int {
+	return compareNumbers(v, reflect.Zero(v.Type()))
+}
+
+// Validator is implemented by specifications, and structs nested in them,
+// that check their own fields once they are populated.
+type Validator interface {
+	Validate() error
+}
+
+// runValidators calls Validate on the struct s points to and on every struct
+// nested in it, children before their parents, and returns the first error
+// prefixed with the type name of the struct that reported it.
+func runValidators(s reflect.Value) error {
+	for s.Kind() == reflect.Ptr {
+		if s.IsNil() {
+			return nil
+		}
+		s = s.Elem()
+	}
+	if s.Kind() != reflect.Struct || !s.CanAddr() {
+		return nil
+	}
+
+	typ := s.Type()
+	for i := 0; i < s.NumField(); i++ {
+		f := s.Field(i)
+		if !f.CanSet() || isTrue(typ.Field(i).Tag.Get("ignored")) {
+			continue
+		}
+		if err := runValidators(f); err != nil {
+			return err
+		}
+	}
+
+	if v, ok := s.Addr().Interface().(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("%s: %w", typ, err)
+		}
+	}
+	return nil
+}